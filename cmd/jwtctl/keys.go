@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const hmacSecretSize = 32
+
+// keygen generates an HMAC secret or a key pair.
+// Key pairs are written as PKCS #8 and PKIX PEM blocks, to stdout or to prefix.pem and prefix.pub.pem files.
+func keygen(args []string, _ io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	keyType := flags.String("type", "hmac", "key type: hmac, rsa, ec or ed25519")
+	bits := flags.Int("bits", 2048, "RSA key size")
+	curve := flags.String("curve", "P-256", "EC curve: P-256, P-384 or P-521")
+	out := flags.String("out", "", "prefix of the key files, instead of stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch *keyType {
+	case "hmac":
+		secret := make([]byte, hmacSecretSize)
+
+		if _, err = rand.Read(secret); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(stdout, base64.RawURLEncoding.EncodeToString(secret))
+
+		return err
+	case "rsa":
+		privateKey, err = rsa.GenerateKey(rand.Reader, *bits)
+	case "ec":
+		var ellipticCurve elliptic.Curve
+
+		ellipticCurve, err = curveByName(*curve)
+		if err == nil {
+			privateKey, err = ecdsa.GenerateKey(ellipticCurve, rand.Reader)
+		}
+	case "ed25519":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return fmt.Errorf("unsupported key type: %s", *keyType)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	if *out == "" {
+		_, err = stdout.Write(append(privatePEM, publicPEM...))
+		return err
+	}
+
+	if err = os.WriteFile(*out+".pem", privatePEM, 0o600); err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(*out+".pub.pem", publicPEM, 0o644); err != nil { //nolint:gosec // Public keys are public.
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "%s.pem\n%s.pub.pem\n", *out, *out)
+
+	return err
+}
+
+// loadKey reads the first private key, public key or certificate of a PEM file.
+func loadKey(path string) (any, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // The key path is set by the user.
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no key found in %s", path)
+		}
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			return x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "PUBLIC KEY":
+			return x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			return x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			return certificate.PublicKey, nil
+		}
+	}
+}
+
+// signingMethod returns the method of the given algorithm, or the default method of the key type.
+func signingMethod(algorithm string, key any) (jwt.SigningMethod, error) {
+	if algorithm != "" {
+		method := jwt.GetSigningMethod(algorithm)
+		if method == nil {
+			return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+		}
+
+		return method, nil
+	}
+
+	switch key := key.(type) {
+	case []byte:
+		return jwt.SigningMethodHS256, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *ecdsa.PrivateKey:
+		return ecMethod(key.Curve)
+	case *ecdsa.PublicKey:
+		return ecMethod(key.Curve)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+}
+
+func ecMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve.Params().Name {
+	case "P-256":
+		return jwt.SigningMethodES256, nil
+	case "P-384":
+		return jwt.SigningMethodES384, nil
+	case "P-521":
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve: %s", curve.Params().Name)
+	}
+}
+
+func curveByName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve: %s", name)
+	}
+}
+
+// jwk is a JSON Web Key, as defined by RFC 7517, with the RSA, EC, OKP and symmetric key parameters.
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	K         string `json:"k"`
+}
+
+// loadJWKS reads the keys of a JWKS file.
+func loadJWKS(path string) ([]jwk, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // The JWKS path is set by the user.
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err = json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %v", err)
+	}
+
+	return jwks.Keys, nil
+}
+
+// findJWK returns the key with the given ID, or the only key of the set when the token has no key ID.
+func findJWK(keys []jwk, keyID string) (jwk, error) {
+	for _, key := range keys {
+		if key.KeyID == keyID && (keyID != "" || len(keys) == 1) {
+			return key, nil
+		}
+	}
+
+	return jwk{}, fmt.Errorf("no JWKS key found for kid %q", keyID)
+}
+
+// publicKey returns the verification key of the JWK.
+func (k jwk) publicKey() (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.KeyType {
+	case "oct":
+		return decode(k.K)
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curve, err := curveByName(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC key coordinates")
+		}
+
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve: %s", k.Curve)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type: %s", k.KeyType)
+	}
+}
@@ -0,0 +1,83 @@
+// Command jwtctl mints, inspects and verifies the tokens of the authentication package, so tokens can be debugged
+// locally instead of on third party websites.
+//
+// Usage:
+//
+//	jwtctl keygen -type hmac|rsa|ec|ed25519 [-out prefix]
+//	jwtctl mint -key private.pem|-secret secret -sub subject -role role [-aud audience] [-ttl 1h]
+//	jwtctl decode token
+//	jwtctl verify -key public.pem|-secret secret|-jwks jwks.json token
+//	jwtctl policy -policy policy.json role:/path...
+//
+// Secrets can also be set with the JWTCTL_SECRET environment variable, so they aren't kept in the shell history.
+// Tokens can be read from the standard input with "-".
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const secretEnv = "JWTCTL_SECRET"
+
+var errUsage = errors.New("usage: jwtctl keygen|mint|decode|verify|policy [flags]")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "jwtctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command of the given arguments.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	commands := map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+		"keygen": keygen,
+		"mint":   mint,
+		"decode": decode,
+		"verify": verify,
+		"policy": checkPolicy,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		return errUsage
+	}
+
+	return command(args[1:], stdin, stdout)
+}
+
+// readToken returns the token argument, which is read from stdin when it is "-".
+func readToken(args []string, stdin io.Reader) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("a single token argument is expected")
+	}
+
+	if args[0] != "-" {
+		return strings.TrimSpace(args[0]), nil
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// secret returns the secret flag value, or the secret environment variable.
+func secret(value string) string {
+	if value != "" {
+		return value
+	}
+
+	return os.Getenv(secretEnv)
+}
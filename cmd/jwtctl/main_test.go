@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout bytes.Buffer
+
+	err := run(args, strings.NewReader(stdin), &stdout)
+
+	return stdout.String(), err
+}
+
+func TestKeygenMintVerify(t *testing.T) {
+	for _, keyType := range []string{"rsa", "ec", "ed25519"} {
+		t.Run(keyType, func(t *testing.T) {
+			prefix := filepath.Join(t.TempDir(), "key")
+
+			_, err := runCommand(t, "", "keygen", "-type", keyType, "-out", prefix)
+			require.NoError(t, err)
+
+			token, err := runCommand(t, "", "mint", "-key", prefix+".pem", "-sub", "user-1", "-role", "user", "-aud", "api")
+			require.NoError(t, err)
+
+			output, err := runCommand(t, token, "verify", "-key", prefix+".pub.pem", "-")
+			require.NoError(t, err)
+
+			var verified decodedToken
+			require.NoError(t, json.Unmarshal([]byte(output), &verified))
+
+			assert.True(t, verified.Verified)
+			assert.Equal(t, "user-1", verified.Claims["sub"])
+			assert.Equal(t, "user", verified.Claims["role"])
+			assert.Equal(t, "api", verified.Claims["aud"])
+
+			// A key of another pair doesn't verify the token.
+			otherPrefix := filepath.Join(t.TempDir(), "other")
+
+			_, err = runCommand(t, "", "keygen", "-type", keyType, "-out", otherPrefix)
+			require.NoError(t, err)
+
+			_, err = runCommand(t, token, "verify", "-key", otherPrefix+".pub.pem", "-")
+			assert.ErrorContains(t, err, "invalid token")
+		})
+	}
+}
+
+func TestMintVerify_Secret(t *testing.T) {
+	secret, err := runCommand(t, "", "keygen", "-type", "hmac")
+	require.NoError(t, err)
+
+	secret = strings.TrimSpace(secret)
+	assert.Len(t, secret, 43)
+
+	t.Setenv(secretEnv, secret)
+
+	token, err := runCommand(t, "", "mint", "-sub", "user-1", "-ttl", "-1m")
+	require.NoError(t, err)
+
+	_, err = runCommand(t, "", "verify", strings.TrimSpace(token))
+	assert.ErrorContains(t, err, "token is expired")
+
+	output, err := runCommand(t, "", "decode", strings.TrimSpace(token))
+	require.NoError(t, err)
+
+	var decoded decodedToken
+	require.NoError(t, json.Unmarshal([]byte(output), &decoded))
+
+	assert.False(t, decoded.Verified)
+	assert.True(t, decoded.Expired)
+	assert.Equal(t, "HS256", decoded.Header["alg"])
+}
+
+func TestVerify_JWKS(t *testing.T) {
+	dir := t.TempDir()
+	encode := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		keyType string
+		jwk     func(key any) map[string]string
+	}{
+		{
+			keyType: "rsa",
+			jwk: func(key any) map[string]string {
+				publicKey := key.(*rsa.PrivateKey).PublicKey
+
+				return map[string]string{
+					"kty": "RSA",
+					"n":   encode(publicKey.N.Bytes()),
+					"e":   encode(big.NewInt(int64(publicKey.E)).Bytes()),
+				}
+			},
+		},
+		{
+			keyType: "ec",
+			jwk: func(key any) map[string]string {
+				publicKey, err := key.(*ecdsa.PrivateKey).PublicKey.Bytes()
+				require.NoError(t, err)
+
+				return map[string]string{
+					"kty": "EC",
+					"crv": "P-256",
+					"x":   encode(publicKey[1:33]),
+					"y":   encode(publicKey[33:]),
+				}
+			},
+		},
+		{
+			keyType: "ed25519",
+			jwk: func(key any) map[string]string {
+				return map[string]string{
+					"kty": "OKP",
+					"crv": "Ed25519",
+					"x":   encode(key.(ed25519.PrivateKey).Public().(ed25519.PublicKey)),
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			prefix := filepath.Join(dir, tt.keyType)
+
+			_, err := runCommand(t, "", "keygen", "-type", tt.keyType, "-out", prefix)
+			require.NoError(t, err)
+
+			key, err := loadKey(prefix + ".pem")
+			require.NoError(t, err)
+
+			jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{tt.jwk(key)}})
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(prefix+".jwks.json", jwks, 0o600))
+
+			token, err := runCommand(t, "", "mint", "-key", prefix+".pem", "-sub", "user-1")
+			require.NoError(t, err)
+
+			_, err = runCommand(t, "", "verify", "-jwks", prefix+".jwks.json", strings.TrimSpace(token))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.json")
+	requestsPath := filepath.Join(dir, "requests.txt")
+
+	require.NoError(t, os.WriteFile(policyPath, []byte(`{
+		"admin_role": "admin",
+		"permissions": {"/orders": ["user"]},
+		"skip": ["/health"]
+	}`), 0o600))
+	require.NoError(t, os.WriteFile(requestsPath, []byte("# sample requests\nadmin:/orders\n:/orders\n"), 0o600))
+
+	output, err := runCommand(t, "", "policy", "-policy", policyPath, "-requests", requestsPath,
+		"user:/orders/1", "guest:/orders", ":/health")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 6)
+
+	assert.Equal(t, []string{"user", "/orders/1", "allow", "/orders", "role", "allowed"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"guest", "/orders", "deny", "/orders", "role", "not", "allowed"}, strings.Fields(lines[2]))
+	assert.Equal(t, "allow", strings.Fields(lines[3])[2])
+	assert.Equal(t, []string{"admin", "/orders", "allow", "-", "admin", "role"}, strings.Fields(lines[4]))
+	assert.Equal(t, []string{"-", "/orders", "deny", "-", "missing", "token"}, strings.Fields(lines[5]))
+
+	_, err = runCommand(t, "", "policy", "-policy", policyPath, "orders")
+	assert.ErrorContains(t, err, "invalid sample request")
+}
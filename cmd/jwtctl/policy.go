@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/audit"
+)
+
+// sampleRequest is a request checked against a policy, written as "role:/path", or ":/path" without a token.
+type sampleRequest struct {
+	role string
+	path string
+}
+
+// checkPolicy runs sample requests through a middleware with the given JSON or YAML policy,
+// and prints the decision of each one.
+func checkPolicy(args []string, _ io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("policy", flag.ContinueOnError)
+	policyPath := flags.String("policy", "", "JSON or YAML policy file")
+	requestsPath := flags.String("requests", "", "file with a role:/path sample request per line")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	policy, err := authentication.LoadPolicy(*policyPath)
+	if err != nil {
+		return err
+	}
+
+	samples, err := sampleRequests(flags.Args(), *requestsPath)
+	if err != nil {
+		return err
+	}
+
+	auth := authentication.Default("jwtctl", 60)
+	sink := audit.NewMemory()
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithPolicy(policy),
+		authentication.WithAuditSink(sink),
+	)
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	writer := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ROLE\tPATH\tOUTCOME\tRULE\tREASON")
+
+	for _, sample := range samples {
+		req := httptest.NewRequest(http.MethodGet, sample.path, nil)
+
+		if sample.role != "" {
+			token, err := auth.ClaimsSignedToken("jwtctl", "jwtctl", "jwtctl", sample.role)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		sink.Reset()
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		outcome, rule, reason := string(authentication.OutcomeDeny), "", "missing token"
+
+		if decisions := sink.Decisions(); len(decisions) > 0 {
+			outcome, rule, reason = string(decisions[0].Outcome), decisions[0].Rule, decisions[0].Reason
+		} else if rr.Code == http.StatusOK {
+			outcome, reason = string(authentication.OutcomeAllow), "skipped or optional endpoint"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", valueOrDash(sample.role), sample.path, outcome,
+			valueOrDash(rule), reason)
+	}
+
+	return writer.Flush()
+}
+
+// sampleRequests parses the sample request arguments, and the lines of the requests file.
+func sampleRequests(args []string, path string) ([]sampleRequest, error) {
+	values := args
+
+	if path != "" {
+		file, err := os.Open(path) //nolint:gosec // The requests path is set by the user.
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				values = append(values, line)
+			}
+		}
+
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, errors.New("no sample requests")
+	}
+
+	samples := make([]sampleRequest, 0, len(values))
+
+	for _, value := range values {
+		role, path, found := strings.Cut(value, ":")
+		if !found || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid sample request %q, expected role:/path", value)
+		}
+
+		samples = append(samples, sampleRequest{role: role, path: path})
+	}
+
+	return samples, nil
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
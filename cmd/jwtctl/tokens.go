@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// mint issues a token signed with a private key or an HMAC secret.
+func mint(args []string, _ io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("mint", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "PEM private key file")
+	secretValue := flags.String("secret", "", "HMAC secret, which defaults to "+secretEnv)
+	algorithm := flags.String("alg", "", "signing algorithm, which defaults to the key type one")
+	subject := flags.String("sub", "", "token subject")
+	role := flags.String("role", "", "token role")
+	audience := flags.String("aud", "", "token audience")
+	issuer := flags.String("iss", "jwtctl", "token issuer")
+	ttl := flags.Duration("ttl", time.Hour, "token lifetime")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *subject == "" {
+		return errors.New("the token subject is required")
+	}
+
+	key, err := flagKey(*keyPath, *secretValue)
+	if err != nil {
+		return err
+	}
+
+	method, err := signingMethod(*algorithm, key)
+	if err != nil {
+		return err
+	}
+
+	auth := authentication.Auth{
+		SigningMethod: method,
+		SigningKey:    key,
+		TokenDuration: *ttl,
+	}
+
+	token, err := auth.ClaimsToken(authentication.NewMapClaims(*subject, *issuer, *audience, *role, *ttl))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, token)
+
+	return err
+}
+
+// decodedToken is the decode command output.
+type decodedToken struct {
+	Header    map[string]any `json:"header"`
+	Claims    jwt.MapClaims  `json:"claims"`
+	IssuedAt  string         `json:"issued_at,omitempty"`
+	ExpiresAt string         `json:"expires_at,omitempty"`
+	Expired   bool           `json:"expired"`
+	Verified  bool           `json:"verified"`
+}
+
+// decode prints the header and claims of a token, without verifying its signature.
+func decode(args []string, stdin io.Reader, stdout io.Writer) error {
+	tokenString, err := readToken(args, stdin)
+	if err != nil {
+		return err
+	}
+
+	if authentication.IsEncrypted(tokenString) {
+		return errors.New("encrypted tokens can't be decoded without their key")
+	}
+
+	claims := jwt.MapClaims{}
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, claims)
+	if err != nil {
+		return err
+	}
+
+	return writeToken(stdout, token.Header, claims, false)
+}
+
+// verify checks the signature and expiration of a token, with a key, an HMAC secret or a JWKS file.
+func verify(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "PEM public key, private key or certificate file")
+	secretValue := flags.String("secret", "", "HMAC secret, which defaults to "+secretEnv)
+	jwksPath := flags.String("jwks", "", "JWKS file")
+	algorithm := flags.String("alg", "", "expected signing algorithm, which defaults to the key type one")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	tokenString, err := readToken(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	var key any
+
+	if *jwksPath != "" {
+		key, *algorithm, err = jwksKey(*jwksPath, tokenString, *algorithm)
+	} else {
+		key, err = flagKey(*keyPath, *secretValue)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+
+	method, err := signingMethod(*algorithm, key)
+	if err != nil {
+		return err
+	}
+
+	auth := authentication.Auth{
+		SigningMethod:   method,
+		VerificationKey: key,
+	}
+
+	claims, err := auth.ParseToken(tokenString)
+	if err != nil {
+		return fmt.Errorf("invalid token: %v", err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return err
+	}
+
+	return writeToken(stdout, token.Header, *claims, true)
+}
+
+// flagKey returns the key of the key file, or the HMAC secret.
+func flagKey(keyPath, secretValue string) (any, error) {
+	if keyPath != "" {
+		return loadKey(keyPath)
+	}
+
+	if value := secret(secretValue); value != "" {
+		return []byte(value), nil
+	}
+
+	return nil, errors.New("a key file or a secret is required")
+}
+
+// jwksKey returns the JWKS key of the token, by its key ID, and its algorithm.
+func jwksKey(path, tokenString, algorithm string) (any, string, error) {
+	keys, err := loadJWKS(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyID, _ := token.Header["kid"].(string)
+
+	jsonKey, err := findJWK(keys, keyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := jsonKey.publicKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if algorithm == "" {
+		algorithm = jsonKey.Algorithm
+	}
+
+	return key, algorithm, nil
+}
+
+func writeToken(w io.Writer, header map[string]any, claims jwt.MapClaims, verified bool) error {
+	output := decodedToken{
+		Header:   header,
+		Claims:   claims,
+		Verified: verified,
+	}
+
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		output.IssuedAt = issuedAt.Format(time.RFC3339)
+	}
+
+	if expirationTime, err := claims.GetExpirationTime(); err == nil && expirationTime != nil {
+		output.ExpiresAt = expirationTime.Format(time.RFC3339)
+		output.Expired = !time.Now().Before(expirationTime.Time)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(output)
+}
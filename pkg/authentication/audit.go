@@ -0,0 +1,89 @@
+package authentication
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Outcome is the result of an authorization decision.
+type Outcome string
+
+const (
+	// OutcomeAllow is the outcome of an allowed request.
+	OutcomeAllow Outcome = "allow"
+	// OutcomeDeny is the outcome of a denied request.
+	OutcomeDeny Outcome = "deny"
+)
+
+// Reasons of the authorization decisions.
+const (
+	// ReasonAdminRole allows the admin role to access every endpoint.
+	ReasonAdminRole = "admin role"
+	// ReasonRoleAllowed allows a role listed in a matching permission rule.
+	ReasonRoleAllowed = "role allowed"
+	// ReasonNoMatchingRule allows endpoints without permission rules.
+	ReasonNoMatchingRule = "no matching rule"
+	// ReasonRoleNotAllowed denies a role that isn't listed in any matching permission rule.
+	ReasonRoleNotAllowed = "role not allowed"
+	// ReasonMissingRole denies tokens without a role claim.
+	ReasonMissingRole = "missing role claim"
+)
+
+// Decision holds the record of an authorization decision.
+//
+// Actors is the delegation chain of exchanged tokens, from the current actor to the first one.
+// Rule is the permission endpoint that decided the outcome, which is empty when no rule was used.
+type Decision struct {
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	Actors  []string  `json:"actors,omitempty"`
+	Roles   []string  `json:"roles"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Method  string    `json:"method"`
+	Route   string    `json:"route"`
+	Rule    string    `json:"rule,omitempty"`
+	Outcome Outcome   `json:"outcome"`
+	Reason  string    `json:"reason"`
+}
+
+// AuditSink records authorization decisions.
+//
+// Record is called while serving requests, so it must not block.
+type AuditSink interface {
+	Record(decision Decision)
+}
+
+// audit records the authorization decision of a request, when an audit sink is set.
+func (m *Middleware) audit(r *http.Request, claims *jwt.MapClaims, role string, allowed bool, rule, reason string) {
+	if m.auditSink == nil {
+		return
+	}
+
+	subject, _ := (*claims)["sub"].(string)
+	tenant, _ := (*claims)[TenantClaim].(string)
+
+	var roles []string
+	if role != "" {
+		roles = []string{role}
+	}
+
+	outcome := OutcomeDeny
+	if allowed {
+		outcome = OutcomeAllow
+	}
+
+	m.auditSink.Record(Decision{
+		Time:    time.Now(),
+		Subject: subject,
+		Actors:  parseActor((*claims)[ActorClaim]).Chain(),
+		Roles:   roles,
+		Tenant:  tenant,
+		Method:  r.Method,
+		Route:   r.URL.Path,
+		Rule:    rule,
+		Outcome: outcome,
+		Reason:  reason,
+	})
+}
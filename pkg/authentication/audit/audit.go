@@ -0,0 +1,161 @@
+// Package audit holds authorization audit sinks for the authentication middlewares.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/loki"
+)
+
+// DefaultBufferSize is the number of decisions buffered by a sink, when no size is set.
+const DefaultBufferSize = 1024
+
+// ErrClosed is returned when closing a sink that was already closed.
+var ErrClosed = errors.New("audit sink is closed")
+
+// Sink is a buffered authentication.AuditSink, that writes decisions in the background.
+//
+// Record never blocks: decisions are dropped when the buffer is full, and counted by Dropped.
+type Sink struct {
+	decisions chan authentication.Decision
+	done      chan struct{}
+	dropped   atomic.Uint64
+	mutex     sync.RWMutex
+	closed    bool
+	write     func(decision authentication.Decision) error
+}
+
+// NewSink is a Sink constructor.
+//
+// size is the number of buffered decisions, which defaults to DefaultBufferSize.
+// write is called for each decision, from a single goroutine. Its errors are logged.
+func NewSink(size int, write func(decision authentication.Decision) error) *Sink {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	s := &Sink{
+		decisions: make(chan authentication.Decision, size),
+		done:      make(chan struct{}),
+		write:     write,
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Record buffers a decision to be written, or drops it when the buffer is full or the sink is closed.
+func (s *Sink) Record(decision authentication.Decision) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+
+	select {
+	case s.decisions <- decision:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of decisions that were not recorded.
+func (s *Sink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops recording decisions, and waits for the buffered ones to be written.
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrClosed
+	}
+
+	s.closed = true
+	close(s.decisions)
+	s.mutex.Unlock()
+
+	<-s.done
+
+	return nil
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+
+	for decision := range s.decisions {
+		if err := s.write(decision); err != nil {
+			log.Println("audit write failed:", err)
+		}
+	}
+}
+
+// NewJSONLines returns a Sink that writes each decision as a JSON line.
+func NewJSONLines(w io.Writer, size int) *Sink {
+	encoder := json.NewEncoder(w)
+
+	return NewSink(size, func(decision authentication.Decision) error {
+		return encoder.Encode(decision)
+	})
+}
+
+// NewLoki returns a Sink that pushes each decision as a JSON log line to a Loki server.
+func NewLoki(client *loki.Loki, size int) *Sink {
+	return NewSink(size, func(decision authentication.Decision) error {
+		line, err := json.Marshal(decision)
+		if err != nil {
+			return err
+		}
+
+		return client.Push(loki.Info, string(line))
+	})
+}
+
+// Memory is an authentication.AuditSink that keeps decisions in memory, which is meant for tests.
+type Memory struct {
+	mutex     sync.Mutex
+	decisions []authentication.Decision
+}
+
+// NewMemory is a Memory constructor.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Record keeps a decision.
+func (m *Memory) Record(decision authentication.Decision) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.decisions = append(m.decisions, decision)
+}
+
+// Decisions returns a copy of the recorded decisions.
+func (m *Memory) Decisions() []authentication.Decision {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	decisions := make([]authentication.Decision, len(m.decisions))
+	copy(decisions, m.decisions)
+
+	return decisions
+}
+
+// Reset removes the recorded decisions.
+func (m *Memory) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.decisions = nil
+}
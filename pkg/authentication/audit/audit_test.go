@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/loki"
+)
+
+var decision = authentication.Decision{
+	Time:    time.Unix(1700000000, 0).UTC(),
+	Subject: "user-1",
+	Roles:   []string{"user"},
+	Method:  http.MethodGet,
+	Route:   "/admin/users",
+	Rule:    "/admin",
+	Outcome: authentication.OutcomeDeny,
+	Reason:  authentication.ReasonRoleNotAllowed,
+}
+
+func TestJSONLines(t *testing.T) {
+	var buffer bytes.Buffer
+
+	sink := NewJSONLines(&buffer, 0)
+	sink.Record(decision)
+	sink.Record(decision)
+
+	require.NoError(t, sink.Close())
+	assert.ErrorIs(t, sink.Close(), ErrClosed)
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var recorded authentication.Decision
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &recorded))
+	assert.Equal(t, decision, recorded)
+
+	// Decisions recorded after closing are dropped.
+	sink.Record(decision)
+	assert.Equal(t, uint64(1), sink.Dropped())
+}
+
+func TestLoki(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		lines []string
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		var streams loki.Streams
+		assert.NoError(t, json.Unmarshal(body, &streams))
+
+		mutex.Lock()
+		lines = append(lines, streams.Streams[0].Values[0][1].(string))
+		mutex.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	sink := NewLoki(loki.New(ts.URL, "test-token", "test-service"), 10)
+	sink.Record(decision)
+	require.NoError(t, sink.Close())
+
+	require.Len(t, lines, 1)
+
+	var recorded authentication.Decision
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &recorded))
+	assert.Equal(t, decision, recorded)
+}
+
+func TestSink_NonBlocking(t *testing.T) {
+	release := make(chan struct{})
+
+	sink := NewSink(1, func(authentication.Decision) error {
+		<-release
+		return nil
+	})
+
+	// The first decision is being written, the second one is buffered, and the others are dropped.
+	for range 5 {
+		sink.Record(decision)
+	}
+
+	assert.Eventually(t, func() bool {
+		return sink.Dropped() >= 3
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	require.NoError(t, sink.Close())
+}
+
+func TestMemory(t *testing.T) {
+	sink := NewMemory()
+	sink.Record(decision)
+
+	assert.Equal(t, []authentication.Decision{decision}, sink.Decisions())
+
+	sink.Reset()
+	assert.Empty(t, sink.Decisions())
+}
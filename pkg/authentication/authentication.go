@@ -2,18 +2,41 @@ package authentication
 
 import (
 	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // Auth is responsible for common operations with authentication and JWT.
+//
+// SigningKey is the key used to sign tokens. It defaults to the claims key, for HMAC signing methods.
+// VerificationKey is the key used to verify tokens. It defaults to the public key of a crypto.Signer
+// signing key, or to the signing key itself.
+// Encryption enables nested signed-then-encrypted tokens. Encrypted tokens are decrypted before verification,
+// and signed only tokens are rejected.
+// VerificationMethods are the signing methods accepted when verifying tokens. It defaults to the signing method.
+// Audience is the audience verified tokens must be issued for. Tokens for any audience are accepted when it's empty.
 type Auth struct {
-	ClaimsKey     ClaimsKey
-	SigningMethod jwt.SigningMethod
-	TokenDuration time.Duration
-	TokenSecret   string
+	ClaimsKey       ClaimsKey
+	SigningMethod   jwt.SigningMethod
+	TokenDuration   time.Duration
+	TokenSecret     string
+	SigningKey      any
+	VerificationKey any
+	Encryption      *Encryption
+
+	VerificationMethods []jwt.SigningMethod
+	Audience            string
+
+	keyID  string
+	tenant *Tenant
 }
 
 // JWT defines methods for JWT authentication, including claims extraction, login, logout, and middleware injection.
@@ -48,3 +71,103 @@ func (a *Auth) SignedToken(claims jwt.MapClaims) (string, error) {
 
 	return token.SignedString([]byte(a.TokenSecret))
 }
+
+// Keyfunc returns the key used to verify a token, after checking its signing method.
+func (a *Auth) Keyfunc(token *jwt.Token) (any, error) {
+	methods := a.VerificationMethods
+	if len(methods) == 0 {
+		methods = []jwt.SigningMethod{a.SigningMethod}
+	}
+
+	if !slices.ContainsFunc(methods, func(method jwt.SigningMethod) bool { return method.Alg() == token.Method.Alg() }) {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return a.verificationKey(), nil
+}
+
+// ParseToken verifies a token and returns its claims. Encrypted tokens are decrypted first.
+//
+// Tokens must be issued for the Auth audience, when it is set.
+// Tokens verified by a tenant Auth must also be issued by the tenant issuer, for the same tenant.
+func (a *Auth) ParseToken(tokenString string) (*jwt.MapClaims, error) {
+	var options []jwt.ParserOption
+
+	if a.Audience != "" {
+		options = append(options, jwt.WithAudience(a.Audience))
+	}
+
+	if a.tenant != nil && a.tenant.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.tenant.Issuer))
+	}
+
+	// Tokens are either all encrypted or none are, so an encrypted token can't be replaced by a signed only one.
+	if IsEncrypted(tokenString) != (a.Encryption != nil) {
+		return nil, ErrInvalidEncryption
+	}
+
+	if a.Encryption != nil {
+		var err error
+
+		tokenString, err = a.Encryption.Decrypt(tokenString)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, a.Keyfunc, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.tenant != nil && claims[TenantClaim] != a.tenant.ID {
+		return nil, ErrTenantMismatch
+	}
+
+	return &claims, nil
+}
+
+func (a *Auth) signingKey() any {
+	if a.SigningKey != nil {
+		return a.SigningKey
+	}
+
+	return []byte(a.ClaimsKey)
+}
+
+func (a *Auth) verificationKey() any {
+	if a.VerificationKey != nil {
+		return a.VerificationKey
+	}
+
+	if signer, ok := a.SigningKey.(crypto.Signer); ok {
+		return signer.Public()
+	}
+
+	return a.signingKey()
+}
+
+// verificationKeyID returns an identifier of a verification key, which changes when the key is rotated.
+func verificationKeyID(key any) string {
+	var data []byte
+
+	switch key := key.(type) {
+	case []byte:
+		data = key
+	case string:
+		data = []byte(key)
+	default:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			der = fmt.Appendf(nil, "%T:%v", key, key)
+		}
+
+		data = der
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,163 @@
+// Package authtest provides helpers to test code protected by the authentication middlewares.
+package authtest
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+const (
+	// Issuer is the issuer of the minted tokens.
+	Issuer = "authtest"
+	// Audience is the audience of the minted tokens.
+	Audience = "authtest"
+	// WrongAudience is the audience of the tokens minted by WrongAudienceToken.
+	WrongAudience = "authtest-wrong-audience"
+)
+
+// Token signs the given claims with the Auth, as authentication.Auth ClaimsToken does, and fails the test on error.
+func Token(tb testing.TB, auth authentication.Auth, claims jwt.MapClaims) string {
+	tb.Helper()
+
+	tokenString, err := auth.ClaimsToken(claims)
+	if err != nil {
+		tb.Fatalf("authtest: token signing failed: %v", err)
+	}
+
+	return tokenString
+}
+
+// ValidToken mints a token for the given subject and role, which is valid for the Auth token duration.
+func ValidToken(tb testing.TB, auth authentication.Auth, subject, role string) string {
+	tb.Helper()
+
+	return Token(tb, auth, authentication.NewMapClaims(subject, Issuer, Audience, role, tokenDuration(auth)))
+}
+
+// ExpiredToken mints a token for the given subject and role, which expired a minute ago.
+func ExpiredToken(tb testing.TB, auth authentication.Auth, subject, role string) string {
+	tb.Helper()
+
+	return Token(tb, auth, authentication.NewMapClaims(subject, Issuer, Audience, role, -time.Minute))
+}
+
+// WrongAudienceToken mints a valid token for the given subject and role, issued for the WrongAudience audience.
+// It is rejected when the Auth Audience is set to Audience.
+func WrongAudienceToken(tb testing.TB, auth authentication.Auth, subject, role string) string {
+	tb.Helper()
+
+	return Token(tb, auth, authentication.NewMapClaims(subject, Issuer, WrongAudience, role, tokenDuration(auth)))
+}
+
+// WrongSignatureToken mints a token for the given subject and role, whose signature doesn't match its content.
+//
+// Encrypted tokens are properly encrypted, so they only fail signature verification.
+func WrongSignatureToken(tb testing.TB, auth authentication.Auth, subject, role string) string {
+	tb.Helper()
+
+	encryption := auth.Encryption
+	auth.Encryption = nil
+
+	parts := strings.Split(ValidToken(tb, auth, subject, role), ".")
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) == 0 {
+		tb.Fatalf("authtest: invalid token signature: %v", err)
+	}
+
+	signature[0] ^= 0xff
+	parts[2] = base64.RawURLEncoding.EncodeToString(signature)
+
+	tokenString := strings.Join(parts, ".")
+	if encryption == nil {
+		return tokenString
+	}
+
+	tokenString, err = encryption.Encrypt(tokenString)
+	if err != nil {
+		tb.Fatalf("authtest: token encryption failed: %v", err)
+	}
+
+	return tokenString
+}
+
+// ContextWithClaims stores the claims in context with the given key, as the middlewares do for valid tokens.
+// The key is the Auth claims key, unless the middleware was built with a different context key.
+func ContextWithClaims(ctx context.Context, key any, claims authentication.Claims) context.Context {
+	mapClaims := jwt.MapClaims{
+		"id":   claims.ID,
+		"sub":  claims.Subject,
+		"iss":  claims.Issuer,
+		"aud":  claims.Audience,
+		"role": claims.Role,
+		"iat":  float64(claims.IssuedAt),
+		"exp":  float64(claims.ExpiresAt),
+	}
+
+	if claims.Tenant != "" {
+		mapClaims[authentication.TenantClaim] = claims.Tenant
+	}
+
+	if claims.ACR != "" {
+		mapClaims["acr"] = claims.ACR
+	}
+
+	if len(claims.AMR) > 0 {
+		amr := make([]any, len(claims.AMR))
+		for i := range claims.AMR {
+			amr[i] = claims.AMR[i]
+		}
+
+		mapClaims["amr"] = amr
+	}
+
+	if claims.AuthTime > 0 {
+		mapClaims["auth_time"] = float64(claims.AuthTime)
+	}
+
+	if claims.Scope != "" {
+		mapClaims[authentication.ScopeClaim] = claims.Scope
+	}
+
+	if claims.Actor != nil {
+		mapClaims[authentication.ActorClaim] = actorClaim(claims.Actor)
+	}
+
+	if claims.Impersonation != "" {
+		mapClaims[authentication.ImpersonationClaim] = claims.Impersonation
+	}
+
+	return context.WithValue(ctx, key, &mapClaims)
+}
+
+// actorClaim returns the actor as a parsed "act" claim value.
+func actorClaim(actor *authentication.Actor) map[string]any {
+	value := map[string]any{"sub": actor.Subject}
+
+	if actor.Actor != nil {
+		value[authentication.ActorClaim] = actorClaim(actor.Actor)
+	}
+
+	return value
+}
+
+// RequestWithClaims returns a copy of the request, with the claims stored in its context with the given key.
+func RequestWithClaims(r *http.Request, key any, claims authentication.Claims) *http.Request {
+	return r.WithContext(ContextWithClaims(r.Context(), key, claims))
+}
+
+func tokenDuration(auth authentication.Auth) time.Duration {
+	if auth.TokenDuration <= 0 {
+		return time.Hour
+	}
+
+	return auth.TokenDuration
+}
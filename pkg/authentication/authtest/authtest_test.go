@@ -0,0 +1,120 @@
+package authtest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+func TestTokens(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encryptionKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	auths := map[string]authentication.Auth{
+		"HMAC": authentication.Default("secret", 3600),
+		"ECDSA": {
+			ClaimsKey:     "claims",
+			SigningMethod: jwt.SigningMethodES256,
+			TokenDuration: time.Hour,
+			SigningKey:    ecdsaKey,
+		},
+		"Encrypted": {
+			ClaimsKey:     "claims",
+			SigningMethod: jwt.SigningMethodHS256,
+			TokenDuration: time.Hour,
+			Encryption: &authentication.Encryption{
+				KeyManagement: authentication.KeyManagementECDHES,
+				PublicKey:     &encryptionKey.PublicKey,
+				PrivateKey:    encryptionKey,
+			},
+		},
+	}
+
+	for name, auth := range auths {
+		t.Run(name, func(t *testing.T) {
+			claims, err := auth.ParseToken(authtest.ValidToken(t, auth, "user-1", "user"))
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", (*claims)["sub"])
+			assert.Equal(t, authtest.Audience, (*claims)["aud"])
+
+			_, err = auth.ParseToken(authtest.ExpiredToken(t, auth, "user-1", "user"))
+			assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+			_, err = auth.ParseToken(authtest.WrongSignatureToken(t, auth, "user-1", "user"))
+			assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+
+			auth.Audience = authtest.Audience
+
+			_, err = auth.ParseToken(authtest.ValidToken(t, auth, "user-1", "user"))
+			require.NoError(t, err)
+
+			_, err = auth.ParseToken(authtest.WrongAudienceToken(t, auth, "user-1", "user"))
+			assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+		})
+	}
+}
+
+func TestRequestWithClaims(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	middleware := authentication.NewMiddleware(auth)
+
+	claims := authentication.NewClaims("user-1", "issuer", "audience", "user", 3600)
+	claims.AMR = []string{authentication.MethodPassword, authentication.MethodOTP}
+	claims.AuthTime = time.Now().Unix()
+	claims.Scope = "orders:read"
+	claims.Actor = &authentication.Actor{Subject: "service-b", Actor: &authentication.Actor{Subject: "service-a"}}
+	req := authtest.RequestWithClaims(httptest.NewRequest(http.MethodGet, "/user", nil), auth.ClaimsKey, claims)
+
+	contextClaims, err := middleware.GetClaims(req.Context())
+	require.NoError(t, err)
+	assert.Equal(t, claims, contextClaims)
+}
+
+func TestSessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := authtest.NewSessionStore()
+
+	require.NoError(t, store.Save(ctx, "session-1", "token-1", time.Minute))
+	require.NoError(t, store.Save(ctx, "session-2", "token-2", time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	assert.Equal(t, map[string]string{"session-1": "token-1"}, store.Sessions())
+
+	ok, err := store.Exists(ctx, "session-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	deleted, err := store.Delete(ctx, "session-1")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	storeErr := errors.New("connection refused")
+	store.SetError(storeErr)
+
+	_, err = store.Exists(ctx, "session-1")
+	assert.ErrorIs(t, err, storeErr)
+
+	assert.Equal(t, []authtest.Call{
+		{Operation: authtest.OperationSave, ID: "session-1", Token: "token-1", TTL: time.Minute},
+		{Operation: authtest.OperationSave, ID: "session-2", Token: "token-2", TTL: time.Nanosecond},
+		{Operation: authtest.OperationExists, ID: "session-2"},
+		{Operation: authtest.OperationDelete, ID: "session-1"},
+		{Operation: authtest.OperationExists, ID: "session-1"},
+	}, store.Calls())
+}
@@ -0,0 +1,178 @@
+package authtest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Session store operations, as recorded by SessionStore.
+const (
+	OperationSave   = "save"
+	OperationExists = "exists"
+	OperationDelete = "delete"
+	OperationDeny   = "deny"
+	OperationDenied = "denied"
+)
+
+// Call is a recorded SessionStore call.
+type Call struct {
+	Operation string
+	ID        string
+	Token     string
+	TTL       time.Duration
+}
+
+type session struct {
+	token     string
+	expiresAt time.Time
+}
+
+// SessionStore is an in-memory authentication.SessionStore, which behaves like the Redis store and records its calls.
+//
+// Sessions expire after their TTL, as Redis keys do.
+type SessionStore struct {
+	mutex    sync.Mutex
+	calls    []Call
+	denied   map[string]time.Time
+	err      error
+	sessions map[string]session
+}
+
+// NewSessionStore is a SessionStore constructor.
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		denied:   map[string]time.Time{},
+		sessions: map[string]session{},
+	}
+}
+
+// Save stores the session of a token, for the given duration.
+func (s *SessionStore) Save(_ context.Context, id, token string, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, Call{Operation: OperationSave, ID: id, Token: token, TTL: ttl})
+
+	if s.err != nil {
+		return s.err
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	s.sessions[id] = session{token: token, expiresAt: expiresAt}
+
+	return nil
+}
+
+// Exists reports whether a session is still active.
+func (s *SessionStore) Exists(_ context.Context, id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, Call{Operation: OperationExists, ID: id})
+
+	if s.err != nil {
+		return false, s.err
+	}
+
+	return s.active(id), nil
+}
+
+// Delete removes a session, and reports whether it was active.
+func (s *SessionStore) Delete(_ context.Context, id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, Call{Operation: OperationDelete, ID: id})
+
+	if s.err != nil {
+		return false, s.err
+	}
+
+	active := s.active(id)
+	delete(s.sessions, id)
+
+	return active, nil
+}
+
+// Deny removes a session and adds its token to the denylist, for the given duration.
+func (s *SessionStore) Deny(_ context.Context, id string, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, Call{Operation: OperationDeny, ID: id, TTL: ttl})
+
+	if s.err != nil {
+		return s.err
+	}
+
+	delete(s.sessions, id)
+	s.denied[id] = time.Now().Add(ttl)
+
+	return nil
+}
+
+// Denied reports whether a token is in the denylist.
+func (s *SessionStore) Denied(_ context.Context, id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, Call{Operation: OperationDenied, ID: id})
+
+	if s.err != nil {
+		return false, s.err
+	}
+
+	expiresAt, ok := s.denied[id]
+
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// Sessions returns the tokens of the active sessions, by session ID.
+func (s *SessionStore) Sessions() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sessions := make(map[string]string, len(s.sessions))
+
+	for id := range s.sessions {
+		if s.active(id) {
+			sessions[id] = s.sessions[id].token
+		}
+	}
+
+	return sessions
+}
+
+// Calls returns the recorded calls, in order.
+func (s *SessionStore) Calls() []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	calls := make([]Call, len(s.calls))
+	copy(calls, s.calls)
+
+	return calls
+}
+
+// SetError makes every following call fail with the given error, as when Redis is unavailable.
+// A nil error restores the store.
+func (s *SessionStore) SetError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err
+}
+
+func (s *SessionStore) active(id string) bool {
+	session, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+
+	return session.expiresAt.IsZero() || time.Now().Before(session.expiresAt)
+}
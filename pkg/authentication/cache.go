@@ -0,0 +1,210 @@
+package authentication
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// denylistCheckInterval is how long a cached token isn't checked against the session store denylist again.
+// Tokens denied by Logout are removed from cache at once, so it only delays revocations made elsewhere.
+const denylistCheckInterval = 5 * time.Second
+
+// tokenCache is a bounded LRU cache of verified token claims, keyed by the hash of the token and of the keys and
+// tenant it was verified with, so a token isn't accepted from cache once its keys are rotated.
+//
+// Entries are kept until their token expires, and they are also indexed by token ID so a logout can remove them.
+type tokenCache struct {
+	mutex   sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	ids     map[string][]*list.Element
+	order   *list.List
+}
+
+type cachedToken struct {
+	key       [sha256.Size]byte
+	id        string
+	claims    jwt.MapClaims
+	expiresAt time.Time
+	checkedAt time.Time
+}
+
+func newTokenCache(size int) *tokenCache {
+	return &tokenCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element, size),
+		ids:     make(map[string][]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// tokenCacheKey returns the cache key of a token verified with the given Auth.
+func tokenCacheKey(auth *Auth, tokenString string) [sha256.Size]byte {
+	hash := sha256.New()
+	hash.Write([]byte(auth.keyID))
+
+	if auth.tenant != nil {
+		hash.Write([]byte(auth.tenant.ID))
+	}
+
+	hash.Write([]byte{0})
+	hash.Write([]byte(tokenString))
+
+	var key [sha256.Size]byte
+	hash.Sum(key[:0])
+
+	return key
+}
+
+// get returns a copy of the cached claims of a token, which can't be expired, and when it was last checked against
+// the denylist.
+func (c *tokenCache) get(key [sha256.Size]byte) (*jwt.MapClaims, time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	cached := element.Value.(*cachedToken)
+	if !time.Now().Before(cached.expiresAt) {
+		c.remove(element)
+		return nil, time.Time{}, false
+	}
+
+	c.order.MoveToFront(element)
+
+	claims := maps.Clone(cached.claims)
+
+	return &claims, cached.checkedAt, true
+}
+
+// checked records that a cached token was checked against the denylist.
+func (c *tokenCache) checked(key [sha256.Size]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cachedToken).checkedAt = time.Now()
+	}
+}
+
+// add caches the claims of a verified token, evicting the least recently used token when the cache is full.
+// Tokens without an expiration time aren't cached.
+func (c *tokenCache) add(key [sha256.Size]byte, claims jwt.MapClaims) {
+	expirationTime, err := claims.GetExpirationTime()
+	if err != nil || expirationTime == nil {
+		return
+	}
+
+	id, _ := claims["id"].(string)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	element := c.order.PushFront(&cachedToken{
+		key:       key,
+		id:        id,
+		claims:    maps.Clone(claims),
+		expiresAt: expirationTime.Time,
+		checkedAt: time.Now(),
+	})
+
+	c.entries[key] = element
+
+	if id != "" {
+		c.ids[id] = append(c.ids[id], element)
+	}
+}
+
+// invalidate removes the cached tokens with the given ID, which are verified with different keys or tenants.
+func (c *tokenCache) invalidate(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, element := range c.ids[id] {
+		c.remove(element)
+	}
+}
+
+func (c *tokenCache) remove(element *list.Element) {
+	cached := c.order.Remove(element).(*cachedToken)
+
+	delete(c.entries, cached.key)
+
+	elements := slices.DeleteFunc(c.ids[cached.id], func(e *list.Element) bool { return e == element })
+	if len(elements) == 0 {
+		delete(c.ids, cached.id)
+	} else {
+		c.ids[cached.id] = elements
+	}
+}
+
+// parseToken verifies a token with the given Auth, or returns its cached claims when a token cache is used,
+// and checks that it isn't in the session store denylist.
+//
+// Cached tokens are only checked again once their last check is older than denylistCheckInterval.
+func (m *Middleware) parseToken(ctx context.Context, auth *Auth, tokenString string) (*jwt.MapClaims, error) {
+	if m.tokenCache == nil {
+		claims, err := auth.ParseToken(tokenString)
+		if err != nil {
+			return nil, err
+		}
+
+		id, _ := (*claims)["id"].(string)
+
+		if err = m.checkDenylist(ctx, id); err != nil {
+			return nil, err
+		}
+
+		return claims, nil
+	}
+
+	// The same token sent for another tenant, or after a key rotation, is verified again, as its cache key differs.
+	key := tokenCacheKey(auth, tokenString)
+
+	claims, checkedAt, cached := m.tokenCache.get(key)
+	if cached && time.Since(checkedAt) < denylistCheckInterval {
+		return claims, nil
+	}
+
+	if !cached {
+		var err error
+
+		claims, err = auth.ParseToken(tokenString)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, _ := (*claims)["id"].(string)
+
+	if err := m.checkDenylist(ctx, id); err != nil {
+		m.tokenCache.invalidate(id)
+		return nil, err
+	}
+
+	if cached {
+		m.tokenCache.checked(key)
+	} else {
+		m.tokenCache.add(key, *claims)
+	}
+
+	return claims, nil
+}
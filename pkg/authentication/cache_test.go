@@ -0,0 +1,264 @@
+package authentication_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+// countingMethod is an HMAC signing method that counts signature verifications.
+type countingMethod struct {
+	*jwt.SigningMethodHMAC
+
+	verifications atomic.Int64
+}
+
+func (m *countingMethod) Alg() string {
+	return "HS256-counting"
+}
+
+func (m *countingMethod) Verify(signingString string, sig []byte, key any) error {
+	m.verifications.Add(1)
+
+	return m.SigningMethodHMAC.Verify(signingString, sig, key)
+}
+
+var verifications = func() *countingMethod {
+	method := &countingMethod{SigningMethodHMAC: jwt.SigningMethodHS256}
+	jwt.RegisterSigningMethod(method.Alg(), func() jwt.SigningMethod { return method })
+
+	return method
+}()
+
+func TestMiddleware_TokenCache(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	auth.SigningMethod = verifications
+
+	middleware := authentication.NewMiddleware(auth, authentication.WithTokenCache(1))
+
+	tokenString, err := auth.ClaimsSignedToken("user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	otherToken, err := auth.ClaimsSignedToken("user-2", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	request := func(tokenString string) authentication.Claims {
+		t.Helper()
+
+		rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, err)
+
+		return claims
+	}
+
+	start := verifications.verifications.Load()
+
+	assert.Equal(t, "user-1", request(tokenString).Subject)
+	assert.Equal(t, "user-1", request(tokenString).Subject)
+	assert.Equal(t, int64(1), verifications.verifications.Load()-start)
+
+	// The cache holds a single token, so the first one is evicted.
+	assert.Equal(t, "user-2", request(otherToken).Subject)
+	assert.Equal(t, "user-1", request(tokenString).Subject)
+	assert.Equal(t, int64(3), verifications.verifications.Load()-start)
+
+	// Logout removes the token from cache, so it is verified again.
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		middleware.Logout(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "user-1", request(tokenString).Subject)
+	assert.Equal(t, int64(4), verifications.verifications.Load()-start)
+
+	rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString+"x")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMiddleware_TokenCacheDenylist(t *testing.T) {
+	store := authtest.NewSessionStore()
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600),
+		authentication.WithSessionStore(store),
+		authentication.WithTokenCache(10),
+	)
+
+	tokenString, err := middleware.Login(context.Background(), "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	denied := func() int {
+		count := 0
+
+		for _, call := range store.Calls() {
+			if call.Operation == authtest.OperationDenied {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	for range 3 {
+		rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	// The denylist verdict of the first request is reused while it is recent.
+	assert.Equal(t, 1, denied())
+
+	// Logout denies the token and removes it from cache, so it is rejected at once.
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		middleware.Logout(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMiddleware_TokenCacheTenants(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tenants := &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", SigningKey: []byte("secret-a")},
+			"tenant-b": {ID: "tenant-b", SigningKey: []byte("secret-b")},
+		},
+	}
+
+	tenantAuth := auth.ForTenant(tenants.tenants["tenant-a"])
+
+	tokenString, err := tenantAuth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Hour))
+	require.NoError(t, err)
+
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithTenants(tenants, authentication.TenantFromHeader("X-Tenant")),
+		authentication.WithTokenCache(10),
+	)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("X-Tenant", "tenant-a")
+
+		rr, _, _ := serve(t, middleware, req, tokenString)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	// A cached token is still rejected for another tenant.
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("X-Tenant", "tenant-b")
+
+	rr, _, _ := serve(t, middleware, req, tokenString)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMiddleware_TokenCacheKeyRotation(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tenants := &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", SigningKey: []byte("secret-a")},
+		},
+	}
+
+	tenantAuth := auth.ForTenant(tenants.tenants["tenant-a"])
+
+	tokenString, err := tenantAuth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Hour))
+	require.NoError(t, err)
+
+	tenantCache := authentication.NewTenantCache(tenants, time.Hour)
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithTenants(tenantCache, authentication.TenantFromHeader("X-Tenant")),
+		authentication.WithTokenCache(10),
+	)
+
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("X-Tenant", "tenant-a")
+
+		rr, _, _ := serve(t, middleware, req, tokenString)
+
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request())
+
+	// Once the tenant key is rotated, the cached token is verified with the new key.
+	tenants.tenants["tenant-a"] = authentication.Tenant{ID: "tenant-a", SigningKey: []byte("rotated-a")}
+	tenantCache.Invalidate("tenant-a")
+
+	assert.Equal(t, http.StatusUnauthorized, request())
+}
+
+func BenchmarkMiddleware_TokenCache(b *testing.B) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(b, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(b, err)
+
+	keys := []struct {
+		name   string
+		method jwt.SigningMethod
+		key    any
+		public any
+	}{
+		{name: "RS256", method: jwt.SigningMethodRS256, key: rsaKey, public: &rsaKey.PublicKey},
+		{name: "ES256", method: jwt.SigningMethodES256, key: ecKey, public: &ecKey.PublicKey},
+	}
+
+	for _, key := range keys {
+		auth := authentication.Auth{
+			ClaimsKey:       "claims",
+			SigningMethod:   key.method,
+			SigningKey:      key.key,
+			VerificationKey: key.public,
+			TokenDuration:   time.Hour,
+		}
+
+		tokenString, err := auth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Hour))
+		require.NoError(b, err)
+
+		for _, size := range []int{0, 1024} {
+			name := key.name + "/NoCache"
+			if size > 0 {
+				name = key.name + "/Cache"
+			}
+
+			b.Run(name, func(b *testing.B) {
+				handler := authentication.NewMiddleware(auth, authentication.WithTokenCache(size)).Middleware(
+					http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+						w.WriteHeader(http.StatusOK)
+					}),
+				)
+
+				req := httptest.NewRequest(http.MethodGet, "/user", nil)
+				req.Header.Set("Authorization", "Bearer "+tokenString)
+
+				b.ReportAllocs()
+
+				for b.Loop() {
+					handler.ServeHTTP(httptest.NewRecorder(), req)
+				}
+			})
+		}
+	}
+}
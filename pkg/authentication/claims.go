@@ -3,6 +3,7 @@ package authentication
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,14 +14,33 @@ import (
 type ClaimsKey string
 
 // Claims defines the JWT payload with standard claims and a custom user role.
+//
+// OriginalIssuedAt is when the session started, which is kept across token renewals and refreshes.
+// ACR, AMR and AuthTime describe how the user authenticated, as used by step-up rules.
+// Scope is the space separated scope of exchanged tokens, and Actor is their delegation chain.
+// Impersonation is the reason of impersonation tokens, whose first actor of the delegation chain is the impersonator.
+// SignedURL reports whether the request was authenticated by a signed URL, instead of a token.
+// Enrichment is the user data loaded by a ClaimsEnricher, which isn't part of the token.
 type Claims struct {
-	ID        string `json:"id"`
-	Subject   string `json:"sub"`
-	Issuer    string `json:"iss"`
-	Audience  string `json:"aud"`
-	ExpiresAt int64  `json:"exp"`
-	IssuedAt  int64  `json:"iat"`
-	Role      string `json:"role"`
+	ID        string   `json:"id"`
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  string   `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Role      string   `json:"role"`
+	Tenant    string   `json:"tenant,omitempty"`
+	ACR       string   `json:"acr,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
+	AuthTime  int64    `json:"auth_time,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Actor     *Actor   `json:"act,omitempty"`
+
+	OriginalIssuedAt int64  `json:"orig_iat,omitempty"`
+	Impersonation    string `json:"impersonation,omitempty"`
+	SignedURL        bool   `json:"signed_url,omitempty"`
+
+	Enrichment *Enrichment `json:"-"`
 }
 
 // NewMapClaims is a jwt.MapClaims constructor.
@@ -31,15 +51,21 @@ type Claims struct {
 // Audience "aud" - intended audience
 // ExpiresAt "exp" - expiration time (Unix)
 // IssuedAt "iat" - issued at (Unix)
+// OriginalIssuedAt "orig_iat" - session start, kept across renewals (Unix)
+//
+// The "auth_time" claim isn't set, since issuing a token doesn't authenticate the user.
 func NewMapClaims(subject, issuer, audience, role string, tokenDuration time.Duration) jwt.MapClaims {
+	now := time.Now()
+
 	return jwt.MapClaims{
-		"id":   uuid.New().String(),
-		"sub":  subject,
-		"iss":  issuer,
-		"aud":  audience,
-		"iat":  time.Now().Unix(),
-		"exp":  time.Now().Add(tokenDuration).Unix(),
-		"role": role,
+		"id":                  uuid.New().String(),
+		"sub":                 subject,
+		"iss":                 issuer,
+		"aud":                 audience,
+		"iat":                 now.Unix(),
+		"exp":                 now.Add(tokenDuration).Unix(),
+		"role":                role,
+		originalIssuedAtClaim: now.Unix(),
 	}
 }
 
@@ -67,19 +93,44 @@ func NewClaims(subject, issuer, audience, role string, tokenDuration time.Durati
 func (a *Auth) ClaimsSignedToken(subject, issuer, audience, role string) (string, error) {
 	claims := NewMapClaims(subject, issuer, audience, role, a.TokenDuration)
 
+	return a.ClaimsToken(claims)
+}
+
+// ClaimsToken signs the given claims with the signing key, as expected by the middlewares.
+//
+// Tokens signed by a tenant Auth are bound to the tenant, and they are encrypted when encryption is configured.
+func (a *Auth) ClaimsToken(claims jwt.MapClaims) (string, error) {
+	if a.tenant != nil {
+		claims[TenantClaim] = a.tenant.ID
+	}
+
 	token := jwt.NewWithClaims(a.SigningMethod, claims)
 
-	return token.SignedString([]byte(a.ClaimsKey))
+	tokenString, err := token.SignedString(a.signingKey())
+	if err != nil || a.Encryption == nil {
+		return tokenString, err
+	}
+
+	return a.Encryption.Encrypt(tokenString)
 }
 
+// ParseClaims extracts the claims stored in context with the Auth claims key.
 func (a *Auth) ParseClaims(ctx context.Context) (Claims, error) {
-	ptrClaims, ok := ctx.Value(a.ClaimsKey).(*jwt.MapClaims)
-	if !ok {
-		return Claims{}, fmt.Errorf("token not found in context")
+	return ClaimsFromContext(ctx, a.ClaimsKey)
+}
+
+// ClaimsFromContext extracts the claims stored in context with the given key.
+func ClaimsFromContext(ctx context.Context, key any) (Claims, error) {
+	ptrClaims, ok := ctx.Value(key).(*jwt.MapClaims)
+	if !ok || ptrClaims == nil {
+		return Claims{}, ErrClaimsNotFound
 	}
 
-	claims := *ptrClaims
+	return parseClaims(*ptrClaims)
+}
 
+// parseClaims reads the claims of a verified token.
+func parseClaims(claims jwt.MapClaims) (Claims, error) {
 	sub, err := claims.GetSubject()
 	if err != nil {
 		return Claims{}, fmt.Errorf("subject  wasn't found in claims")
@@ -110,14 +161,62 @@ func (a *Auth) ParseClaims(ctx context.Context) (Claims, error) {
 		return Claims{}, fmt.Errorf("issuer wasn't found in claims")
 	}
 
+	audience, _ := claims["aud"].(string)
+	tenant, _ := claims[TenantClaim].(string)
+	impersonation, _ := claims[ImpersonationClaim].(string)
+	signedURL, _ := claims[SignedURLClaim].(bool)
+	userAuth := userAuthentication(claims)
+
 	authClaims := Claims{
 		ID:        id.(string),
 		Subject:   sub,
+		Audience:  audience,
 		Role:      role.(string),
+		Tenant:    tenant,
 		Issuer:    issuer,
 		IssuedAt:  issuedAt.Unix(),
 		ExpiresAt: expirationTime.Unix(),
+		ACR:       userAuth.ACR,
+		AMR:       userAuth.AMR,
+		Scope:     strings.Join(parseScope(claims[ScopeClaim]), " "),
+		Actor:     parseActor(claims[ActorClaim]),
+
+		Impersonation: impersonation,
+		SignedURL:     signedURL,
+	}
+
+	if originalIssuedAt, ok := numericClaim(claims, originalIssuedAtClaim); ok {
+		authClaims.OriginalIssuedAt = originalIssuedAt.Unix()
+	}
+
+	if !userAuth.Time.IsZero() {
+		authClaims.AuthTime = userAuth.Time.Unix()
 	}
 
 	return authClaims, nil
 }
+
+// UserAuthentication returns how the user authenticated, from the "acr", "amr" and "auth_time" claims.
+func (c Claims) UserAuthentication() UserAuthentication {
+	userAuth := UserAuthentication{
+		ACR: c.ACR,
+		AMR: c.AMR,
+	}
+
+	if c.AuthTime > 0 {
+		userAuth.Time = time.Unix(c.AuthTime, 0)
+	}
+
+	return userAuth
+}
+
+// Scopes returns the values of the "scope" claim.
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// ActorChain returns the subjects of the delegation chain, from the current actor to the first one.
+// It is empty when the token isn't delegated.
+func (c Claims) ActorChain() []string {
+	return c.Actor.Chain()
+}
@@ -2,26 +2,38 @@ package jwt
 
 import (
 	"context"
-	"fmt"
+	"net/http"
 
 	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
 )
 
 // GetClaims allows to extract claims from context.
 func (j *JWT) GetClaims(ctx context.Context) (authentication.Claims, error) {
-	return j.auth.ParseClaims(ctx)
+	return j.middleware.GetClaims(ctx)
 }
 
 // Logout removes claims from the context, effectively logging the user out.
 func (j *JWT) Logout(ctx context.Context) context.Context {
-	return context.WithValue(ctx, j.auth.ClaimsKey, nil)
+	return j.middleware.Logout(ctx)
 }
 
-func (j *JWT) Login(_ context.Context, subject, issuer, audience, role string) (string, error) {
-	tokenString, err := j.auth.ClaimsSignedToken(subject, issuer, audience, role)
-	if err != nil {
-		return "", fmt.Errorf("login failed: %v", err)
-	}
+// Login issues a signed token for the given user data.
+func (j *JWT) Login(ctx context.Context, subject, issuer, audience, role string) (string, error) {
+	return j.middleware.Login(ctx, subject, issuer, audience, role)
+}
+
+// Exchange swaps a subject token for a downscoped token, when token exchange is enabled.
+// It implements authentication.TokenExchanger, so the authentication handler mounts its endpoint.
+func (j *JWT) Exchange(ctx context.Context, request authentication.ExchangeRequest) (string, []string, error) {
+	return j.middleware.Exchange(ctx, request)
+}
+
+// TenantContext returns the request context with the request tenant, when multi-tenancy is used.
+func (j *JWT) TenantContext(r *http.Request) (context.Context, error) {
+	return j.middleware.TenantContext(r)
+}
 
-	return tokenString, err
+// Authentication returns the authentication middleware, for the features this adapter doesn't expose.
+func (j *JWT) Authentication() *authentication.Middleware {
+	return j.middleware
 }
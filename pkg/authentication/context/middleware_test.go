@@ -127,3 +127,16 @@ func TestJWT_Middleware(t *testing.T) {
 		})
 	}
 }
+
+func TestNew(t *testing.T) {
+	permissionsMap := map[string][]string{"/admin": {"admin"}}
+
+	jwtMiddleware := New("admin", []string{"/public"}, permissionsMap, authentication.Default("secret", 3600))
+
+	assert.Equal(t, "admin", jwtMiddleware.AdminRole)
+	assert.Equal(t, []string{"/public"}, jwtMiddleware.SkipList)
+	assert.Equal(t, permissionsMap, jwtMiddleware.PermissionsMap)
+
+	var _ authentication.JWT = &jwtMiddleware
+	var _ authentication.TokenExchanger = &jwtMiddleware
+}
@@ -4,17 +4,12 @@ import (
 	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
 )
 
-const (
-	expiredTokenMessage = "token has expired"
-	unauthorizedMessage = "unauthorized"
-)
-
 // JWT is a JWTMiddleware that holds authentication data and dependencies.
 //
+// It is an adapter of the authentication middleware, which it builds once, with the given settings.
+// Its fields hold those settings, so changing them doesn't change the middleware.
+//
 // adminRole is the maximum permission role, that allows everything by default.
-// claimsKey is the authentication key used by claims.
-// tokenSecret the secret key to verify the integrity and authenticity of the JWT
-// tokenMaxAge is the max duration of a token, in nanoseconds.
 // skipList is the list of endpoints that are ignored for JWT verification.
 // permissionsMap is the list of endpoints, associated to the allowed permission roles.
 type JWT struct {
@@ -22,27 +17,31 @@ type JWT struct {
 	PermissionsMap map[string][]string
 	SkipList       []string
 
-	auth authentication.Auth
+	middleware *authentication.Middleware
 }
 
 // New is a JWT middleware constructor.
 //
 // adminRole is the maximum permission role, that allows everything by default.
-// claimsKey is the authentication key used by claims.
-// tokenSecret the secret key to verify the integrity and authenticity of the JWT
-// tokenMaxAge is the max duration of a token, in nanoseconds.
 // skipList is the list of endpoints that are ignored for JWT verification.
 // permissionsMap is the list of endpoints, associated to the allowed permission roles.
+// auth holds the claims key, signing method and token duration.
+// options configure the remaining middleware features, such as token renewal or multi-tenancy.
 func New(
 	adminRole string,
 	skipList []string,
 	permissionsMap map[string][]string,
-	authentication authentication.Auth,
+	auth authentication.Auth,
+	options ...authentication.Option,
 ) JWT {
 	return JWT{
 		AdminRole:      adminRole,
 		PermissionsMap: permissionsMap,
 		SkipList:       skipList,
-		auth:           authentication,
+		middleware: authentication.NewMiddleware(auth, append([]authentication.Option{
+			authentication.WithAdminRole(adminRole),
+			authentication.WithSkip(skipList...),
+			authentication.WithPermissions(permissionsMap),
+		}, options...)...),
 	}
 }
@@ -0,0 +1,193 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Enrichment holds the user data that isn't carried by tokens, such as permissions, groups and feature flags.
+//
+// Profile holds application specific user attributes.
+type Enrichment struct {
+	Permissions []string          `json:"permissions,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+	Features    map[string]bool   `json:"features,omitempty"`
+	Profile     map[string]string `json:"profile,omitempty"`
+}
+
+// ClaimsEnricher adds user data to the claims of valid tokens, and it is run by the middlewares after validation.
+type ClaimsEnricher interface {
+	// Enrich returns the user data of the claims.
+	Enrich(ctx context.Context, claims Claims) (Enrichment, error)
+	// Invalidate discards the user data of the claims, which is called on logout.
+	Invalidate(ctx context.Context, claims Claims) error
+}
+
+// EnrichmentLoader loads the user data of the claims, usually from the application database.
+type EnrichmentLoader func(ctx context.Context, claims Claims) (Enrichment, error)
+
+// EnrichmentCache caches user data by key, until it expires.
+type EnrichmentCache interface {
+	// Get returns the cached user data of the key, and reports whether it was found.
+	Get(ctx context.Context, key string) (Enrichment, bool, error)
+	// Set caches the user data of the key for the given duration.
+	Set(ctx context.Context, key string, enrichment Enrichment, ttl time.Duration) error
+	// Delete removes the cached user data of the key.
+	Delete(ctx context.Context, key string) error
+}
+
+// CachedEnricher is a ClaimsEnricher that loads user data with an EnrichmentLoader, and caches it per subject.
+type CachedEnricher struct {
+	cache  EnrichmentCache
+	loader EnrichmentLoader
+	ttl    time.Duration
+}
+
+// NewCachedEnricher is a CachedEnricher constructor.
+//
+// loader loads the user data on cache misses.
+// cache holds the loaded data for the given duration. It defaults to an in-memory cache when nil.
+func NewCachedEnricher(loader EnrichmentLoader, cache EnrichmentCache, ttl time.Duration) *CachedEnricher {
+	if cache == nil {
+		cache = NewMemoryEnrichmentCache()
+	}
+
+	return &CachedEnricher{
+		cache:  cache,
+		loader: loader,
+		ttl:    ttl,
+	}
+}
+
+// Enrich returns the cached user data of the claims subject, or loads and caches it.
+// Cache errors are logged, so the data is still loaded when the cache is unavailable.
+func (e *CachedEnricher) Enrich(ctx context.Context, claims Claims) (Enrichment, error) {
+	key := enrichmentKey(claims)
+
+	enrichment, ok, err := e.cache.Get(ctx, key)
+	if err != nil {
+		log.Println("enrichment cache error:", err)
+	} else if ok {
+		return enrichment, nil
+	}
+
+	enrichment, err = e.loader(ctx, claims)
+	if err != nil {
+		return Enrichment{}, fmt.Errorf("enrichment load failed: %v", err)
+	}
+
+	if err = e.cache.Set(ctx, key, enrichment, e.ttl); err != nil {
+		log.Println("enrichment cache error:", err)
+	}
+
+	return enrichment, nil
+}
+
+// Invalidate removes the cached user data of the claims subject.
+func (e *CachedEnricher) Invalidate(ctx context.Context, claims Claims) error {
+	return e.cache.Delete(ctx, enrichmentKey(claims))
+}
+
+// enrichmentKey returns the cache key of the claims subject, which is scoped by tenant.
+func enrichmentKey(claims Claims) string {
+	if claims.Tenant == "" {
+		return claims.Subject
+	}
+
+	return claims.Tenant + "/" + claims.Subject
+}
+
+// MemoryEnrichmentCache is an in-memory EnrichmentCache, for single instance deployments and tests.
+type MemoryEnrichmentCache struct {
+	entries map[string]enrichmentEntry
+	mutex   sync.Mutex
+}
+
+type enrichmentEntry struct {
+	enrichment Enrichment
+	expiresAt  time.Time
+}
+
+// NewMemoryEnrichmentCache is a MemoryEnrichmentCache constructor.
+func NewMemoryEnrichmentCache() *MemoryEnrichmentCache {
+	return &MemoryEnrichmentCache{
+		entries: map[string]enrichmentEntry{},
+	}
+}
+
+// Get returns the cached user data of the key, and reports whether it was found.
+func (c *MemoryEnrichmentCache) Get(_ context.Context, key string) (Enrichment, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return Enrichment{}, false, nil
+	}
+
+	return entry.enrichment, true, nil
+}
+
+// Set caches the user data of the key for the given duration. Expired entries are removed on set.
+func (c *MemoryEnrichmentCache) Set(_ context.Context, key string, enrichment Enrichment, ttl time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	for entryKey, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, entryKey)
+		}
+	}
+
+	c.entries[key] = enrichmentEntry{
+		enrichment: enrichment,
+		expiresAt:  now.Add(ttl),
+	}
+
+	return nil
+}
+
+// Delete removes the cached user data of the key.
+func (c *MemoryEnrichmentCache) Delete(_ context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, key)
+
+	return nil
+}
+
+type enrichmentContextKey struct{}
+
+// enrich runs the claims enricher, when it is set, and stores the user data in context.
+func (m *Middleware) enrich(ctx context.Context, mapClaims *jwt.MapClaims) (context.Context, error) {
+	if m.enricher == nil {
+		return ctx, nil
+	}
+
+	claims, err := parseClaims(*mapClaims)
+	if err != nil {
+		return ctx, err
+	}
+
+	enrichment, err := m.enricher.Enrich(ctx, claims)
+	if err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, enrichmentContextKey{}, &enrichment), nil
+}
+
+// EnrichmentFromContext extracts the user data stored in context by the claims enricher.
+func EnrichmentFromContext(ctx context.Context) (*Enrichment, bool) {
+	enrichment, ok := ctx.Value(enrichmentContextKey{}).(*Enrichment)
+
+	return enrichment, ok && enrichment != nil
+}
@@ -0,0 +1,78 @@
+package authentication_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestMiddleware_ClaimsEnricher(t *testing.T) {
+	ctx := context.Background()
+	auth := authentication.Default("secret", 3600)
+
+	var loads atomic.Int32
+
+	enricher := authentication.NewCachedEnricher(
+		func(_ context.Context, claims authentication.Claims) (authentication.Enrichment, error) {
+			loads.Add(1)
+
+			if claims.Subject == "user-2" {
+				return authentication.Enrichment{}, errors.New("user not found")
+			}
+
+			return authentication.Enrichment{
+				Permissions: []string{"orders:read"},
+				Features:    map[string]bool{"beta": true},
+			}, nil
+		},
+		nil,
+		time.Minute,
+	)
+
+	middleware := authentication.NewMiddleware(auth, authentication.WithClaimsEnricher(enricher))
+
+	tokenString, err := middleware.Login(ctx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	for range 2 {
+		rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), tokenString)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, err)
+		require.NotNil(t, claims.Enrichment)
+
+		assert.Equal(t, []string{"orders:read"}, claims.Enrichment.Permissions)
+		assert.True(t, claims.Enrichment.Features["beta"])
+	}
+
+	assert.Equal(t, int32(1), loads.Load())
+
+	// Logging out discards the cached user data, so it's loaded again.
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		middleware.Logout(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	rr, _, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), tokenString)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), loads.Load())
+
+	t.Run("Load failure", func(t *testing.T) {
+		tokenString, err := middleware.Login(ctx, "user-2", "issuer", "audience", "user")
+		require.NoError(t, err)
+
+		rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), tokenString)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
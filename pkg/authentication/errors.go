@@ -0,0 +1,49 @@
+package authentication
+
+import "errors"
+
+var (
+	// ErrUnauthorized is returned when a request has no valid credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when a request has valid credentials, which aren't allowed to access the endpoint.
+	ErrForbidden = errors.New("forbidden")
+	// ErrTokenExpired is returned when a token is past its expiration time.
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrInvalidCredentials is returned by an Authenticator when the given credentials are rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidRequest is returned when a request body can't be decoded.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrClaimsNotFound is returned when there are no claims stored in context.
+	ErrClaimsNotFound = errors.New("token not found in context")
+	// ErrAnonymous is returned when there are no claims, because an optional endpoint was requested without a token.
+	ErrAnonymous = errors.New("anonymous request")
+	// ErrSessionNotFound is returned when a token session isn't active, because it was revoked or expired.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTenantNotFound is returned when a request tenant can't be resolved.
+	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrTenantMismatch is returned when a token was issued for a different tenant.
+	ErrTenantMismatch = errors.New("token was issued for a different tenant")
+	// ErrInsufficientUserAuthentication is returned when a route requires a stronger or more recent authentication,
+	// so the client should run a step-up flow.
+	ErrInsufficientUserAuthentication = errors.New("insufficient_user_authentication")
+	// ErrTokenUsed is returned when a single-use token was already consumed.
+	ErrTokenUsed = errors.New("token already used")
+	// ErrWrongPurpose is returned when a single-use token was issued for another purpose.
+	ErrWrongPurpose = errors.New("wrong token purpose")
+	// ErrInvalidScope is returned when a token exchange requests a scope beyond the subject token scope.
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrExchangeNotAllowed is returned when a token exchange actor isn't a client of the exchange policy.
+	ErrExchangeNotAllowed = errors.New("token exchange not allowed")
+	// ErrInvalidTarget is returned when a token exchange requests an audience the client can't request.
+	ErrInvalidTarget = errors.New("invalid target")
+	// ErrImpersonationNotAllowed is returned when a user can't impersonate the target user.
+	ErrImpersonationNotAllowed = errors.New("impersonation not allowed")
+	// ErrInvalidSignature is returned when a signed URL has an invalid signature, or it doesn't allow the request.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrMissingSecret is returned when URLs are signed with an Auth that has no HMAC secret.
+	ErrMissingSecret = errors.New("missing HMAC secret")
+	// ErrInvalidPolicy is returned when a policy can't be decoded or has invalid rules.
+	ErrInvalidPolicy = errors.New("invalid policy")
+	// ErrInvalidEncryption is returned when a token can't be encrypted or decrypted.
+	ErrInvalidEncryption = errors.New("invalid token encryption")
+)
@@ -0,0 +1,299 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Token exchange grant and token types, as defined by RFC 8693.
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// Delegation claims, as defined by RFC 8693.
+const (
+	ActorClaim = "act"
+	ScopeClaim = "scope"
+)
+
+// Actor is the party acting on behalf of the token subject, as the "act" claim.
+//
+// Actor holds the previous actor of the delegation chain, when the token was exchanged more than once.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// Chain returns the subjects of the delegation chain, from the current actor to the first one.
+func (a *Actor) Chain() []string {
+	var chain []string
+
+	for actor := a; actor != nil; actor = actor.Actor {
+		chain = append(chain, actor.Subject)
+	}
+
+	return chain
+}
+
+// claim returns the actor as a claim value, in the same form as parsed claims.
+func (a *Actor) claim() map[string]any {
+	value := map[string]any{"sub": a.Subject}
+
+	if a.Actor != nil {
+		value[ActorClaim] = a.Actor.claim()
+	}
+
+	return value
+}
+
+// parseActor reads an "act" claim value, which is nil when the token isn't delegated.
+func parseActor(value any) *Actor {
+	var actClaims map[string]any
+
+	switch act := value.(type) {
+	case map[string]any:
+		actClaims = act
+	case jwt.MapClaims:
+		actClaims = act
+	default:
+		return nil
+	}
+
+	subject, _ := actClaims["sub"].(string)
+
+	return &Actor{
+		Subject: subject,
+		Actor:   parseActor(actClaims[ActorClaim]),
+	}
+}
+
+// parseScope reads a "scope" claim value, as a space separated string or a list of strings.
+func parseScope(value any) []string {
+	switch scope := value.(type) {
+	case string:
+		return strings.Fields(scope)
+	case []string:
+		return slices.Clone(scope)
+	case []any:
+		var values []string
+
+		for _, item := range scope {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+// ExchangeClient sets the tokens a client, the actor of token exchanges, can request.
+//
+// Audiences is the list of audiences the client can request tokens for.
+// Scopes is the list of scopes the client can request. Empty allows any scope.
+// Role is the role of the exchanged tokens, which replaces the subject role, so they are only authorized on the
+// endpoints of the client role. Empty issues tokens without role, which are only authorized by their scope.
+type ExchangeClient struct {
+	Audiences []string
+	Scopes    []string
+	Role      string
+}
+
+// ExchangePolicy sets the clients that can exchange tokens, by the subject of their actor token.
+type ExchangePolicy struct {
+	Clients map[string]ExchangeClient
+}
+
+// ExchangeRequest is a token exchange request.
+//
+// SubjectToken is the token of the party on whose behalf the request is made, usually a user.
+// ActorToken is the token of the party acting on behalf of the subject, usually the calling service, which must be
+// a client of the exchange policy.
+// Audience is the target audience of the new token, which the client must be allowed to request.
+// Scope is the requested scope, which must be within the subject token scope when it has one, and within the client
+// scopes when it has them. It defaults to the subject token scope, and it is required when the subject token has
+// no scope.
+type ExchangeRequest struct {
+	SubjectToken string
+	ActorToken   string
+	Audience     string
+	Scope        []string
+}
+
+// TokenExchanger exchanges a subject token for a new token, for a target audience.
+// It returns the new token and its granted scope.
+type TokenExchanger interface {
+	Exchange(ctx context.Context, request ExchangeRequest) (string, []string, error)
+}
+
+// Exchange swaps a subject token for a downscoped token for the request audience, as defined by RFC 8693, and
+// returns it with its granted scope. Token exchange is enabled with WithTokenExchange.
+//
+// The new token keeps the subject and user authentication of the subject token, and it doesn't outlive it. Its
+// role is the client one, so it never has more privileges than the client allows.
+// The actor token subject is added to the "act" claim chain, before the actors of the subject token.
+// It fails with ErrExchangeNotAllowed when the actor isn't a client of the exchange policy, with ErrInvalidTarget
+// when the client can't request the audience, and with ErrInvalidScope when it can't request the scope.
+// When a session store is used, both tokens must have an active session, and the new token session is saved.
+// When multi-tenancy is used, both tokens must belong to the tenant stored in context, or to the subject token one.
+func (m *Middleware) Exchange(ctx context.Context, request ExchangeRequest) (string, []string, error) {
+	if request.SubjectToken == "" || request.ActorToken == "" || request.Audience == "" {
+		return "", nil, ErrInvalidRequest
+	}
+
+	if len(m.exchange.Clients) == 0 {
+		return "", nil, fmt.Errorf("%w: token exchange isn't enabled", ErrExchangeNotAllowed)
+	}
+
+	// Both tokens are verified with the keys of the subject token tenant, and the new token is issued for it.
+	if _, ok := TenantFromContext(ctx); !ok {
+		if tenantID := TenantFromClaim(TenantClaim)(nil, request.SubjectToken); tenantID != "" {
+			ctx = ContextWithTenant(ctx, tenantID)
+		}
+	}
+
+	s, err := m.contextScope(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	subjectClaims, err := m.exchangeClaims(ctx, &s.auth, request.SubjectToken)
+	if err != nil {
+		return "", nil, err
+	}
+
+	actorClaims, err := m.exchangeClaims(ctx, &s.auth, request.ActorToken)
+	if err != nil {
+		return "", nil, err
+	}
+
+	actorSubject, _ := actorClaims["sub"].(string)
+	actor := &Actor{Subject: actorSubject, Actor: parseActor(subjectClaims[ActorClaim])}
+
+	client, ok := m.exchange.Clients[actorSubject]
+	if !ok {
+		return "", nil, ErrExchangeNotAllowed
+	}
+
+	if !slices.Contains(client.Audiences, request.Audience) {
+		return "", nil, ErrInvalidTarget
+	}
+
+	scope := parseScope(subjectClaims[ScopeClaim])
+	if len(request.Scope) > 0 {
+		for _, value := range request.Scope {
+			if len(scope) > 0 && !slices.Contains(scope, value) {
+				return "", nil, ErrInvalidScope
+			}
+		}
+
+		scope = request.Scope
+	}
+
+	// The new token is downscoped, so its scope can't be empty.
+	if len(scope) == 0 {
+		return "", nil, fmt.Errorf("%w: a scope is required", ErrInvalidScope)
+	}
+
+	for _, value := range scope {
+		if len(client.Scopes) > 0 && !slices.Contains(client.Scopes, value) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+
+	now := time.Now()
+
+	// Renewals don't extend the new token past the subject token expiration.
+	expirationLimit, ok := numericClaim(subjectClaims, expirationLimitClaim)
+	if !ok {
+		expirationLimit, ok = numericClaim(subjectClaims, "exp")
+	}
+
+	expiresAt := now.Add(s.auth.TokenDuration)
+	if ok && expirationLimit.Before(expiresAt) {
+		expiresAt = expirationLimit
+	}
+
+	subject, _ := subjectClaims["sub"].(string)
+
+	claims := jwt.MapClaims{
+		"id":   uuid.NewString(),
+		"sub":  subject,
+		"iss":  subjectClaims["iss"],
+		"aud":  request.Audience,
+		"iat":  now.Unix(),
+		"exp":  expiresAt.Unix(),
+		"role": client.Role,
+	}
+
+	if ok {
+		claims[expirationLimitClaim] = expirationLimit.Unix()
+	}
+
+	// Exchanged impersonation tokens are still flagged as such.
+	for _, name := range []string{TenantClaim, ImpersonationClaim} {
+		if value, ok := subjectClaims[name]; ok {
+			claims[name] = value
+		}
+	}
+
+	userAuthentication(subjectClaims).SetClaims(claims)
+
+	claims[ScopeClaim] = strings.Join(scope, " ")
+
+	claims[ActorClaim] = actor.claim()
+
+	tokenString, err := s.auth.ClaimsToken(claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("token exchange failed: %v", err)
+	}
+
+	err = m.saveSession(ctx, claims["id"].(string), subject, tokenString, time.Until(expiresAt))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, scope, nil
+}
+
+// exchangeClaims verifies a token of an exchange request, which must be an access token with an active session.
+func (m *Middleware) exchangeClaims(ctx context.Context, auth *Auth, tokenString string) (jwt.MapClaims, error) {
+	claims, err := auth.ParseToken(tokenString)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+
+		return nil, ErrUnauthorized
+	}
+
+	if _, ok := (*claims)[PurposeClaim]; ok {
+		return nil, ErrUnauthorized
+	}
+
+	if m.sessions != nil {
+		id, _ := (*claims)["id"].(string)
+
+		ok, err := m.sessions.Exists(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("session store error: %v", err)
+		}
+
+		if !ok {
+			return nil, ErrSessionNotFound
+		}
+	}
+
+	return *claims, nil
+}
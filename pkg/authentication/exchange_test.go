@@ -0,0 +1,376 @@
+package authentication_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+// exchangePolicy lets service-a exchange tokens for orders, and service-b for billing and invoices.
+var exchangePolicy = authentication.ExchangePolicy{
+	Clients: map[string]authentication.ExchangeClient{
+		"service-a": {Audiences: []string{"orders"}, Role: "orders-client"},
+		"service-b": {Audiences: []string{"billing", "invoices"}, Scopes: []string{"orders:read"}, Role: "billing-client"},
+	},
+}
+
+func TestMiddleware_Exchange(t *testing.T) {
+	ctx := context.Background()
+	auth := authentication.Default("secret", 3600)
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithSessionStore(authtest.NewSessionStore()),
+		authentication.WithTokenExchange(exchangePolicy),
+	)
+
+	userToken, err := middleware.Login(
+		authentication.ContextWithUserAuthentication(ctx, authentication.UserAuthentication{
+			AMR:  []string{authentication.MethodPassword},
+			Time: time.Now(),
+		}),
+		"user-1", "issuer", "gateway", "admin",
+	)
+	require.NoError(t, err)
+
+	serviceA, err := middleware.Login(ctx, "service-a", "issuer", "gateway", "service")
+	require.NoError(t, err)
+
+	serviceB, err := middleware.Login(ctx, "service-b", "issuer", "gateway", "service")
+	require.NoError(t, err)
+
+	serviceC, err := middleware.Login(ctx, "service-c", "issuer", "gateway", "service")
+	require.NoError(t, err)
+
+	exchanged, scope, err := middleware.Exchange(ctx, authentication.ExchangeRequest{
+		SubjectToken: userToken,
+		ActorToken:   serviceA,
+		Audience:     "orders",
+		Scope:        []string{"orders:read", "orders:write"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders:read", "orders:write"}, scope)
+
+	delegated, _, err := middleware.Exchange(ctx, authentication.ExchangeRequest{
+		SubjectToken: exchanged,
+		ActorToken:   serviceB,
+		Audience:     "billing",
+		Scope:        []string{"orders:read"},
+	})
+	require.NoError(t, err)
+
+	rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), delegated)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+
+	// The subject role is replaced by the client one, so an admin token isn't exchanged for an admin token.
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "billing", claims.Audience)
+	assert.Equal(t, "billing-client", claims.Role)
+	assert.Equal(t, []string{"orders:read"}, claims.Scopes())
+	assert.Equal(t, []string{"service-b", "service-a"}, claims.ActorChain())
+	assert.Equal(t, []string{authentication.MethodPassword}, claims.AMR)
+
+	t.Run("Refresh", func(t *testing.T) {
+		mux := http.NewServeMux()
+		authentication.NewHandler(middleware, mockAuthenticator{}, "issuer", "gateway").Register(mux)
+
+		req := httptest.NewRequest(http.MethodPost, "/token/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+delegated)
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Subject token scope", func(t *testing.T) {
+		tokenString, scope, err := middleware.Exchange(ctx, authentication.ExchangeRequest{
+			SubjectToken: delegated,
+			ActorToken:   serviceB,
+			Audience:     "invoices",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"orders:read"}, scope)
+
+		claims, err := auth.ParseToken(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, "orders:read", (*claims)[authentication.ScopeClaim])
+	})
+
+	tests := []struct {
+		name        string
+		request     authentication.ExchangeRequest
+		expectedErr error
+	}{
+		{
+			name: "Scope beyond subject token scope",
+			request: authentication.ExchangeRequest{
+				SubjectToken: delegated,
+				ActorToken:   serviceB,
+				Audience:     "billing",
+				Scope:        []string{"orders:write"},
+			},
+			expectedErr: authentication.ErrInvalidScope,
+		},
+		{
+			name: "Scope beyond client scopes",
+			request: authentication.ExchangeRequest{
+				SubjectToken: userToken,
+				ActorToken:   serviceB,
+				Audience:     "billing",
+				Scope:        []string{"orders:write"},
+			},
+			expectedErr: authentication.ErrInvalidScope,
+		},
+		{
+			name:        "Missing scope",
+			request:     authentication.ExchangeRequest{SubjectToken: userToken, ActorToken: serviceA, Audience: "orders"},
+			expectedErr: authentication.ErrInvalidScope,
+		},
+		{
+			name: "Missing actor token",
+			request: authentication.ExchangeRequest{
+				SubjectToken: userToken,
+				Audience:     "orders",
+				Scope:        []string{"orders:read"},
+			},
+			expectedErr: authentication.ErrInvalidRequest,
+		},
+		{
+			name: "Unknown client",
+			request: authentication.ExchangeRequest{
+				SubjectToken: userToken,
+				ActorToken:   serviceC,
+				Audience:     "orders",
+				Scope:        []string{"orders:read"},
+			},
+			expectedErr: authentication.ErrExchangeNotAllowed,
+		},
+		{
+			name: "Audience not allowed",
+			request: authentication.ExchangeRequest{
+				SubjectToken: userToken,
+				ActorToken:   serviceA,
+				Audience:     "billing",
+				Scope:        []string{"orders:read"},
+			},
+			expectedErr: authentication.ErrInvalidTarget,
+		},
+		{
+			name: "Invalid actor token",
+			request: authentication.ExchangeRequest{
+				SubjectToken: userToken,
+				ActorToken:   "invalid-token",
+				Audience:     "orders",
+			},
+			expectedErr: authentication.ErrUnauthorized,
+		},
+		{
+			name:        "Missing audience",
+			request:     authentication.ExchangeRequest{SubjectToken: userToken, ActorToken: serviceA},
+			expectedErr: authentication.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := middleware.Exchange(ctx, tt.request)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+
+	t.Run("Purpose token", func(t *testing.T) {
+		purposeToken, err := authentication.NewPurposeTokens(auth, nil).
+			Issue("user-1", authentication.PurposeMagicLink, time.Minute)
+		require.NoError(t, err)
+
+		_, _, err = middleware.Exchange(ctx, authentication.ExchangeRequest{
+			SubjectToken: purposeToken,
+			ActorToken:   serviceA,
+			Audience:     "orders",
+		})
+		assert.ErrorIs(t, err, authentication.ErrUnauthorized)
+	})
+
+	t.Run("Exchange not enabled", func(t *testing.T) {
+		_, _, err := authentication.NewMiddleware(auth).Exchange(ctx, authentication.ExchangeRequest{
+			SubjectToken: userToken,
+			ActorToken:   serviceA,
+			Audience:     "orders",
+			Scope:        []string{"orders:read"},
+		})
+		assert.ErrorIs(t, err, authentication.ErrExchangeNotAllowed)
+	})
+}
+
+func TestHandler_Exchange(t *testing.T) {
+	ctx := context.Background()
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600),
+		authentication.WithTokenExchange(exchangePolicy),
+	)
+
+	mux := http.NewServeMux()
+	authentication.NewHandler(middleware, mockAuthenticator{}, "issuer", "audience").Register(mux)
+
+	userToken := login(t, mux).AccessToken
+
+	serviceToken, err := middleware.Login(ctx, "service-a", "issuer", "audience", "service")
+	require.NoError(t, err)
+
+	scopedToken, _, err := middleware.Exchange(ctx, authentication.ExchangeRequest{
+		SubjectToken: userToken,
+		ActorToken:   serviceToken,
+		Audience:     "orders",
+		Scope:        []string{"orders:read"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		bearer         string
+		expectedStatus int
+		expectedScope  string
+	}{
+		{
+			name: "Valid request",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {userToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"actor_token":        {serviceToken},
+				"actor_token_type":   {authentication.TokenTypeJWT},
+				"audience":           {"orders"},
+				"scope":              {"orders:read"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "orders:read",
+		},
+		{
+			name: "Client authenticated with bearer token",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {scopedToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"audience":           {"orders"},
+			},
+			bearer:         serviceToken,
+			expectedStatus: http.StatusOK,
+			expectedScope:  "orders:read",
+		},
+		{
+			name: "Unauthenticated client",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {userToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"audience":           {"orders"},
+				"scope":              {"orders:read"},
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Unknown client",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {userToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"actor_token":        {userToken},
+				"actor_token_type":   {authentication.TokenTypeAccessToken},
+				"audience":           {"orders"},
+				"scope":              {"orders:read"},
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name: "Audience not allowed",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {userToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"audience":           {"billing"},
+				"scope":              {"orders:read"},
+			},
+			bearer:         serviceToken,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid grant type",
+			form: url.Values{
+				"grant_type":         {"password"},
+				"subject_token":      {userToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"audience":           {"orders"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid subject token type",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {userToken},
+				"subject_token_type": {"urn:ietf:params:oauth:token-type:saml2"},
+				"audience":           {"orders"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Missing scope",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {userToken},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"audience":           {"orders"},
+			},
+			bearer:         serviceToken,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid subject token",
+			form: url.Values{
+				"grant_type":         {authentication.TokenExchangeGrantType},
+				"subject_token":      {"invalid-token"},
+				"subject_token_type": {authentication.TokenTypeAccessToken},
+				"audience":           {"orders"},
+			},
+			bearer:         serviceToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/token/exchange", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response authentication.TokenResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+			assert.Equal(t, authentication.TokenTypeAccessToken, response.IssuedTokenType)
+			assert.Equal(t, tt.expectedScope, response.Scope)
+			assert.NotEmpty(t, response.AccessToken)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package authentication
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TokenExtractor extracts the token of a request. It returns an empty string when there's none.
+type TokenExtractor func(r *http.Request) string
+
+// TokenFromBearer extracts the token from the Authorization header, with the Bearer scheme.
+func TokenFromBearer() TokenExtractor {
+	return func(r *http.Request) string {
+		return strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
+	}
+}
+
+// TokenFromHeader extracts the token from a request header.
+func TokenFromHeader(header string) TokenExtractor {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// TokenFromCookie extracts the token from a request cookie.
+func TokenFromCookie(name string) TokenExtractor {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+
+		return cookie.Value
+	}
+}
+
+// TokenFromAny returns the first token found by the given extractors.
+func TokenFromAny(extractors ...TokenExtractor) TokenExtractor {
+	return func(r *http.Request) string {
+		for _, extractor := range extractors {
+			if token := extractor(r); token != "" {
+				return token
+			}
+		}
+
+		return ""
+	}
+}
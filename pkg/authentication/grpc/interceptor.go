@@ -0,0 +1,228 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// authorityMetadata is the pseudo-header of the call authority, which is the request host.
+const authorityMetadata = ":authority"
+
+// Interceptor authenticates gRPC calls with the tokens of the "authorization" metadata,
+// and checks role permissions by full method name, such as "/pkg.Service/Method".
+//
+// Calls are authorized by an authentication middleware, as HTTP requests to the full method name path, with the
+// call metadata as headers, so they share its policy, multi-tenancy, step-up rules, audit sink and token cache.
+type Interceptor struct {
+	middleware *authentication.Middleware
+	options    []authentication.Option
+}
+
+// Option configures an Interceptor.
+type Option func(i *Interceptor)
+
+// WithAdminRole sets the maximum permission role, that allows every method.
+func WithAdminRole(role string) Option {
+	return WithMiddlewareOptions(authentication.WithAdminRole(role))
+}
+
+// WithPermissions sets the methods, by full method name prefix, associated to the allowed permission roles.
+// A prefix such as "/pkg.Service/" sets the permissions of every service method, and the longest prefix of a
+// method sets its allowed roles.
+func WithPermissions(permissionsMap map[string][]string) Option {
+	return WithMiddlewareOptions(authentication.WithPermissions(permissionsMap))
+}
+
+// WithSkip sets the methods, by full method name prefix, that are ignored for JWT verification,
+// such as health checks.
+func WithSkip(methods ...string) Option {
+	return WithMiddlewareOptions(authentication.WithSkip(methods...))
+}
+
+// WithSessionStore requires tokens to have an active session in the given store, such as the Redis one.
+func WithSessionStore(store authentication.SessionStore) Option {
+	return WithMiddlewareOptions(authentication.WithSessionStore(store))
+}
+
+// WithMiddlewareOptions sets further options of the middleware that authorizes calls, such as multi-tenancy,
+// step-up rules, an audit sink or a token cache.
+func WithMiddlewareOptions(options ...authentication.Option) Option {
+	return func(i *Interceptor) {
+		i.options = append(i.options, options...)
+	}
+}
+
+// New is an Interceptor constructor.
+//
+// auth holds the token verification settings, shared with the HTTP middlewares.
+// options configure the permissions, skipped methods, session store and the remaining middleware options.
+func New(auth authentication.Auth, options ...Option) *Interceptor {
+	i := &Interceptor{}
+
+	for _, option := range options {
+		option(i)
+	}
+
+	i.middleware = authentication.NewMiddleware(auth, i.options...)
+
+	return i
+}
+
+// NewWithMiddleware is an Interceptor constructor, that authorizes calls with the given middleware,
+// so they follow the same policy as the HTTP requests it serves, including its policy reloads.
+func NewWithMiddleware(middleware *authentication.Middleware) *Interceptor {
+	return &Interceptor{
+		middleware: middleware,
+	}
+}
+
+// Unary returns a unary server interceptor, which stores the token claims in the handler context.
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := i.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns a stream server interceptor, which stores the token claims in the stream context.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// GetClaims extracts the claims stored in context by the interceptors.
+func (i *Interceptor) GetClaims(ctx context.Context) (authentication.Claims, error) {
+	return i.middleware.GetClaims(ctx)
+}
+
+// authenticate verifies the call token and permissions, and returns the context with its claims.
+// Tokens aren't renewed, since calls have no response headers to send them in.
+func (i *Interceptor) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fullMethod, nil)
+	if err != nil {
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	}
+
+	setHeaders(ctx, r)
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+
+	ctx, err = i.middleware.Authorize(nil, r)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// Sessions are checked when the claims are read, as they are for HTTP requests.
+	if _, err = i.middleware.GetClaims(ctx); err != nil &&
+		!errors.Is(err, authentication.ErrClaimsNotFound) && !errors.Is(err, authentication.ErrAnonymous) {
+		return nil, statusError(err)
+	}
+
+	return ctx, nil
+}
+
+// statusError returns the gRPC status of an authorization error.
+func statusError(err error) error {
+	var stepUpErr *authentication.StepUpError
+
+	switch {
+	case errors.Is(err, authentication.ErrForbidden):
+		return status.Error(codes.PermissionDenied, authentication.ErrForbidden.Error())
+	case errors.As(err, &stepUpErr):
+		return stepUpStatus(stepUpErr)
+	case errors.Is(err, authentication.ErrTokenExpired), errors.Is(err, authentication.ErrSessionNotFound):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, authentication.ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, authentication.ErrUnauthorized.Error())
+	default:
+		log.Println(err)
+		return status.Error(codes.Unavailable, "session store error")
+	}
+}
+
+// stepUpStatus returns the gRPC status of a step-up error, which is unauthenticated, as its HTTP response is.
+// Its details hold the error challenge, and the required authentication context classes and maximum age.
+func stepUpStatus(stepUpErr *authentication.StepUpError) error {
+	info := &errdetails.ErrorInfo{
+		Reason: stepUpErr.Error(),
+		Domain: "authentication",
+		Metadata: map[string]string{
+			"www-authenticate": stepUpErr.Challenge(),
+		},
+	}
+
+	if len(stepUpErr.Rule.ACR) > 0 {
+		info.Metadata["acr_values"] = strings.Join(stepUpErr.Rule.ACR, " ")
+	}
+
+	if stepUpErr.Rule.MaxAge > 0 {
+		info.Metadata["max_age"] = strconv.FormatInt(int64(stepUpErr.Rule.MaxAge.Seconds()), 10)
+	}
+
+	st, err := status.New(codes.Unauthenticated, stepUpErr.Error()).WithDetails(info)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, stepUpErr.Error())
+	}
+
+	return st.Err()
+}
+
+// setHeaders sets the incoming call metadata as request headers, such as "authorization" or a tenant header,
+// and the call authority as the request host. Other pseudo-headers are ignored.
+func setHeaders(ctx context.Context, r *http.Request) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return
+	}
+
+	for key, values := range md {
+		if key == authorityMetadata && len(values) > 0 {
+			r.Host = values[0]
+			continue
+		}
+
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+}
+
+// serverStream is a grpc.ServerStream with the authenticated context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the authenticated context.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,240 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+func incomingContext(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestInterceptor_Unary(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	sessions := authtest.NewSessionStore()
+	middleware := authentication.NewMiddleware(auth, authentication.WithSessionStore(sessions))
+
+	userToken, err := middleware.Login(context.Background(), "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	adminToken, err := middleware.Login(context.Background(), "admin-1", "issuer", "audience", "admin")
+	require.NoError(t, err)
+
+	interceptor := New(auth,
+		WithAdminRole("admin"),
+		WithPermissions(map[string][]string{
+			"/orders.Orders/":            {"user", "manager"},
+			"/orders.Orders/DeleteOrder": {"manager"},
+		}),
+		WithSkip("/grpc.health.v1.Health/"),
+		WithSessionStore(sessions),
+	)
+
+	tests := []struct {
+		name            string
+		method          string
+		token           string
+		expectedCode    codes.Code
+		expectedSubject string
+	}{
+		{
+			name:            "Allowed role",
+			method:          "/orders.Orders/GetOrder",
+			token:           userToken,
+			expectedCode:    codes.OK,
+			expectedSubject: "user-1",
+		},
+		{
+			name:         "Denied role",
+			method:       "/orders.Orders/DeleteOrder",
+			token:        userToken,
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:            "Service prefix",
+			method:          "/orders.Orders/ListOrders",
+			token:           userToken,
+			expectedCode:    codes.OK,
+			expectedSubject: "user-1",
+		},
+		{
+			name:            "Admin role",
+			method:          "/orders.Orders/DeleteOrder",
+			token:           adminToken,
+			expectedCode:    codes.OK,
+			expectedSubject: "admin-1",
+		},
+		{
+			name:         "Missing token",
+			method:       "/orders.Orders/GetOrder",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Invalid token",
+			method:       "/orders.Orders/GetOrder",
+			token:        authtest.WrongSignatureToken(t, auth, "user-1", "user"),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Expired token",
+			method:       "/orders.Orders/GetOrder",
+			token:        authtest.ExpiredToken(t, auth, "user-1", "user"),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Token without session",
+			method:       "/orders.Orders/GetOrder",
+			token:        authtest.ValidToken(t, auth, "user-1", "user"),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Skipped method",
+			method:       "/grpc.health.v1.Health/Check",
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+
+			_, err := interceptor.Unary()(
+				incomingContext(tt.token),
+				nil,
+				&grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, _ any) (any, error) {
+					claims, err := interceptor.GetClaims(ctx)
+					if err == nil {
+						subject = claims.Subject
+					}
+
+					return nil, nil
+				},
+			)
+
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			assert.Equal(t, tt.expectedSubject, subject)
+		})
+	}
+}
+
+// fakeServerStream is a grpc.ServerStream with the given context.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestInterceptor_Stream(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	interceptor := New(auth, WithPermissions(map[string][]string{"/orders.Orders/": {"user"}}))
+
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.Orders/WatchOrders"}
+
+	var claims authentication.Claims
+
+	err := interceptor.Stream()(
+		nil,
+		fakeServerStream{ctx: incomingContext(authtest.ValidToken(t, auth, "user-1", "user"))},
+		info,
+		func(_ any, stream grpc.ServerStream) error {
+			var err error
+
+			claims, err = interceptor.GetClaims(stream.Context())
+
+			return err
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	err = interceptor.Stream()(
+		nil,
+		fakeServerStream{ctx: incomingContext(authtest.ValidToken(t, auth, "user-1", "guest"))},
+		info,
+		func(_ any, _ grpc.ServerStream) error {
+			return nil
+		},
+	)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestInterceptor_Metadata(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	interceptor := New(auth, WithMiddlewareOptions(
+		authentication.WithExtractor(authentication.TokenFromHeader("X-Api-Token")),
+	))
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("x-api-token", authtest.ValidToken(t, auth, "user-1", "user")))
+
+	var claims authentication.Claims
+
+	_, err := interceptor.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/GetOrder"},
+		func(ctx context.Context, _ any) (any, error) {
+			var err error
+
+			claims, err = interceptor.GetClaims(ctx)
+
+			return nil, err
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+}
+
+func TestInterceptor_StepUp(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	interceptor := New(auth, WithMiddlewareOptions(authentication.WithStepUp(authentication.StepUpRule{
+		Path:   "/payouts.Payouts/",
+		ACR:    []string{"urn:example:mfa"},
+		MaxAge: 5 * time.Minute,
+	})))
+
+	claims := jwt.MapClaims{"sub": "user-1", "role": "user", "exp": time.Now().Add(time.Hour).Unix()}
+	authentication.UserAuthentication{ACR: "urn:example:pwd", Time: time.Now()}.SetClaims(claims)
+
+	token, err := auth.ClaimsToken(claims)
+	require.NoError(t, err)
+
+	_, err = interceptor.Unary()(
+		incomingContext(token),
+		nil,
+		&grpc.UnaryServerInfo{FullMethod: "/payouts.Payouts/CreatePayout"},
+		func(_ context.Context, _ any) (any, error) {
+			return nil, nil
+		},
+	)
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unauthenticated, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, authentication.ErrInsufficientUserAuthentication.Error(), info.GetReason())
+	assert.Equal(t, "urn:example:mfa", info.GetMetadata()["acr_values"])
+	assert.Equal(t, "300", info.GetMetadata()["max_age"])
+	assert.Contains(t, info.GetMetadata()["www-authenticate"], `error="insufficient_user_authentication"`)
+}
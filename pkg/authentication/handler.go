@@ -0,0 +1,366 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ribeirohugo/go_middlewares/internal/model"
+)
+
+const tokenType = "Bearer"
+
+// maxTokenRequestSize is the maximum body size of token requests, in bytes.
+const maxTokenRequestSize = 1 << 12
+
+// Authenticator verifies user credentials on behalf of the token handler.
+//
+// Implementations should return ErrInvalidCredentials when the credentials are rejected.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (Identity, error)
+}
+
+// Identity holds the authenticated user data used to issue a token.
+type Identity struct {
+	Subject string
+	Role    string
+}
+
+// TokenRequest is the JSON body accepted by the token endpoint.
+//
+// Device is an optional label of the client device, kept with the session metadata.
+type TokenRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Device   string `json:"device,omitempty"`
+}
+
+// TokenResponse is the JSON body returned by the token, refresh and token exchange endpoints.
+//
+// IssuedTokenType and Scope are only returned by the token exchange endpoint.
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// Handler holds the HTTP handlers for login, token refresh, logout and session introspection.
+type Handler struct {
+	audience      string
+	authenticator Authenticator
+	issuer        string
+	jwt           JWT
+}
+
+// NewHandler is a Handler constructor.
+//
+// jwtMiddleware is the middleware used to issue, validate and revoke tokens.
+// authenticator verifies user credentials on the token endpoint.
+// issuer and audience are the "iss" and "aud" claims of issued tokens.
+func NewHandler(jwtMiddleware JWT, authenticator Authenticator, issuer, audience string) Handler {
+	return Handler{
+		audience:      audience,
+		authenticator: authenticator,
+		issuer:        issuer,
+		jwt:           jwtMiddleware,
+	}
+}
+
+// Register mounts the handlers in the given mux.
+//
+// Refresh, logout and session endpoints are wrapped with the JWT middleware, while the token and token exchange
+// endpoints are left public. If the mux is wrapped with the same middleware, "/token" should be in its skip list.
+// The token exchange endpoint is only mounted when the JWT middleware implements TokenExchanger.
+func (h Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /token", h.Token)
+
+	if _, ok := h.jwt.(TokenExchanger); ok {
+		mux.HandleFunc("POST /token/exchange", h.Exchange)
+	}
+
+	mux.Handle("POST /token/refresh", h.jwt.Middleware(http.HandlerFunc(h.Refresh)))
+	mux.Handle("POST /logout", h.jwt.Middleware(http.HandlerFunc(h.Logout)))
+	mux.Handle("GET /session", h.jwt.Middleware(http.HandlerFunc(h.Session)))
+}
+
+// Token checks the request credentials with the Authenticator and issues a new token.
+func (h Handler) Token(w http.ResponseWriter, r *http.Request) {
+	var request TokenRequest
+
+	err := decodeJSON(w, r, &request)
+	if err != nil || request.Username == "" {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrInvalidRequest)
+			return
+		}
+
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest)
+
+		return
+	}
+
+	// The tenant is resolved first, so the authenticator can read it from context.
+	ctx, err := h.tenantContext(r)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	identity, err := h.authenticator.Authenticate(ctx, request.Username, request.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			writeError(w, http.StatusUnauthorized, ErrInvalidCredentials)
+			return
+		}
+
+		log.Println("authenticate error:", err)
+		writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+
+		return
+	}
+
+	ctx = ContextWithSessionMetadata(ctx, RequestSessionMetadata(r, request.Device))
+	ctx = ContextWithUserAuthentication(ctx, UserAuthentication{
+		AMR:  []string{MethodPassword},
+		Time: time.Now(),
+	})
+
+	h.issue(ctx, w, identity.Subject, identity.Role)
+}
+
+// Refresh revokes the current token and issues a new one for the same subject and role.
+// The new token keeps the current session start and user authentication, and its session keeps the current session
+// metadata. Refresh fails past the session maximum lifetime, and it is forbidden for exchanged and impersonation
+// tokens.
+func (h Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.jwt.GetClaims(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+		return
+	}
+
+	// Exchanged tokens are downscoped and don't outlive their subject token, so they are exchanged again instead,
+	// and impersonation tokens are short-lived, so impersonation is requested again.
+	if claims.Scope != "" || claims.Actor != nil || claims.Impersonation != "" {
+		writeError(w, http.StatusForbidden, ErrForbidden)
+		return
+	}
+
+	metadata := RequestSessionMetadata(r, "")
+	metadata.RenewedFrom = claims.ID
+
+	ctx := ContextWithSessionMetadata(h.jwt.Logout(r.Context()), metadata)
+	ctx = ContextWithUserAuthentication(ctx, claims.UserAuthentication())
+
+	originalIssuedAt := claims.OriginalIssuedAt
+	if originalIssuedAt == 0 {
+		originalIssuedAt = claims.IssuedAt
+	}
+
+	ctx = ContextWithOriginalIssuedAt(ctx, time.Unix(originalIssuedAt, 0))
+
+	h.issue(ctx, w, claims.Subject, claims.Role)
+}
+
+// Exchange swaps the subject token of a form encoded RFC 8693 request for a token for the requested audience.
+//
+// Subject and actor tokens must be access tokens or JWTs, issued by the JWT middleware. Clients without an actor
+// token are authenticated with their Authorization Bearer token instead.
+func (h Handler) Exchange(w http.ResponseWriter, r *http.Request) {
+	exchanger, ok := h.jwt.(TokenExchanger)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New(http.StatusText(http.StatusNotImplemented)))
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != TokenExchangeGrantType {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest)
+		return
+	}
+
+	request := ExchangeRequest{
+		SubjectToken: r.PostForm.Get("subject_token"),
+		ActorToken:   r.PostForm.Get("actor_token"),
+		Audience:     r.PostForm.Get("audience"),
+		Scope:        strings.Fields(r.PostForm.Get("scope")),
+	}
+
+	if !isExchangeTokenType(r.PostForm.Get("subject_token_type")) ||
+		(request.ActorToken != "" && !isExchangeTokenType(r.PostForm.Get("actor_token_type"))) {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest)
+		return
+	}
+
+	if request.ActorToken == "" {
+		request.ActorToken = TokenFromBearer()(r)
+	}
+
+	if request.ActorToken == "" {
+		writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+		return
+	}
+
+	// Without a request tenant, the subject token tenant is used.
+	ctx, err := h.tenantContext(r)
+	if err != nil && !errors.Is(err, ErrTenantNotFound) {
+		writeTenantError(w, err)
+		return
+	}
+
+	token, scope, err := exchanger.Exchange(ctx, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrInvalidScope), errors.Is(err, ErrInvalidTarget):
+			writeError(w, http.StatusBadRequest, err)
+		case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrTokenExpired), errors.Is(err, ErrSessionNotFound):
+			writeError(w, http.StatusUnauthorized, err)
+		case errors.Is(err, ErrExchangeNotAllowed):
+			writeError(w, http.StatusForbidden, err)
+		default:
+			log.Println("token exchange error:", err)
+			writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		}
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TokenResponse{
+		AccessToken:     token,
+		IssuedTokenType: TokenTypeAccessToken,
+		TokenType:       tokenType,
+		ExpiresIn:       expiresIn(token),
+		Scope:           strings.Join(scope, " "),
+	})
+}
+
+// Logout revokes the current token.
+func (h Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	_, err := h.jwt.GetClaims(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+		return
+	}
+
+	h.jwt.Logout(r.Context())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Session returns the claims of the current token.
+func (h Handler) Session(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.jwt.GetClaims(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func (h Handler) issue(ctx context.Context, w http.ResponseWriter, subject, role string) {
+	token, err := h.jwt.Login(ctx, subject, h.issuer, h.audience, role)
+	if errors.Is(err, ErrTokenExpired) {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err != nil {
+		log.Println("login error:", err)
+		writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   tokenType,
+		ExpiresIn:   expiresIn(token),
+	})
+}
+
+// decodeJSON decodes a bounded JSON request body, which must be a single value without unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, value any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTokenRequestSize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+
+		return errors.New("unexpected data after the JSON body")
+	}
+
+	return nil
+}
+
+// tenantContext returns the request context with the request tenant, when the JWT middleware resolves tenants.
+func (h Handler) tenantContext(r *http.Request) (context.Context, error) {
+	tenants, ok := h.jwt.(interface {
+		TenantContext(r *http.Request) (context.Context, error)
+	})
+	if !ok {
+		return r.Context(), nil
+	}
+
+	return tenants.TenantContext(r)
+}
+
+// writeTenantError writes the error of a request whose tenant can't be resolved.
+func writeTenantError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrTenantNotFound) || errors.Is(err, ErrTenantMismatch) {
+		writeError(w, http.StatusBadRequest, ErrTenantNotFound)
+		return
+	}
+
+	log.Println("tenant resolve error:", err)
+	writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+}
+
+func isExchangeTokenType(tokenType string) bool {
+	return tokenType == TokenTypeAccessToken || tokenType == TokenTypeJWT
+}
+
+// expiresIn returns the remaining lifetime of a token issued by this package, in seconds.
+// Encrypted tokens can't be read without their key, so zero is returned for them.
+func expiresIn(token string) int64 {
+	expirationTime := expiresAt(token)
+	if expirationTime.IsZero() {
+		return 0
+	}
+
+	return int64(time.Until(expirationTime).Seconds())
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, model.Error{
+		Message: err.Error(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		log.Println("JSON marshal error:", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(bodyJSON); err != nil {
+		log.Println("Write error:", err)
+	}
+}
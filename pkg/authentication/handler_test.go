@@ -0,0 +1,228 @@
+package authentication_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	jwtcontext "github.com/ribeirohugo/go_middlewares/pkg/authentication/context"
+)
+
+type mockAuthenticator struct{}
+
+func (mockAuthenticator) Authenticate(_ context.Context, username, password string) (authentication.Identity, error) {
+	if username != "john" || password != "secret" {
+		return authentication.Identity{}, authentication.ErrInvalidCredentials
+	}
+
+	return authentication.Identity{Subject: "user-1", Role: "user"}, nil
+}
+
+func newTestMux() *http.ServeMux {
+	jwtMiddleware := jwtcontext.New("admin", nil, nil, authentication.Default("secret", 3600))
+
+	mux := http.NewServeMux()
+	authentication.NewHandler(&jwtMiddleware, mockAuthenticator{}, "issuer", "audience").Register(mux)
+
+	return mux
+}
+
+func login(t *testing.T, mux *http.ServeMux) authentication.TokenResponse {
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(`{"username":"john","password":"secret"}`))
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response authentication.TokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+	return response
+}
+
+func TestHandler_Token(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid credentials",
+			body:           `{"username":"john","password":"secret"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid credentials",
+			body:           `{"username":"john","password":"wrong"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message":"invalid credentials"}`,
+		},
+		{
+			name:           "Invalid body",
+			body:           `not-json`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"invalid request"}`,
+		},
+		{
+			name:           "Unknown field",
+			body:           `{"username":"john","password":"secret","role":"admin"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"invalid request"}`,
+		},
+		{
+			name:           "Trailing data",
+			body:           `{"username":"john","password":"secret"}{"username":"jane"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"invalid request"}`,
+		},
+		{
+			name:           "Body too large",
+			body:           `{"username":"john","password":"` + strings.Repeat("a", 1<<12) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"message":"invalid request"}`,
+		},
+	}
+
+	mux := newTestMux()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			mux.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Session(t *testing.T) {
+	mux := newTestMux()
+	token := login(t, mux)
+
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.InDelta(t, 3600, token.ExpiresIn, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/session", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var claims authentication.Claims
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &claims))
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "user", claims.Role)
+	assert.Equal(t, "issuer", claims.Issuer)
+	assert.Equal(t, "audience", claims.Audience)
+}
+
+func TestHandler_Refresh(t *testing.T) {
+	mux := newTestMux()
+	token := login(t, mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var refreshed authentication.TokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &refreshed))
+	assert.NotEmpty(t, refreshed.AccessToken)
+	assert.NotEqual(t, token.AccessToken, refreshed.AccessToken)
+}
+
+func TestHandler_RefreshMaxLifetime(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithRenewal(authentication.Renewal{Window: time.Minute, MaxLifetime: 2 * time.Hour}))
+
+	mux := http.NewServeMux()
+	authentication.NewHandler(middleware, mockAuthenticator{}, "issuer", "audience").Register(mux)
+
+	tests := []struct {
+		name             string
+		originalIssuedAt time.Time
+		expectedStatus   int
+		expectedExpires  time.Time
+	}{
+		{
+			name:             "Within maximum lifetime",
+			originalIssuedAt: time.Now().Add(-90 * time.Minute),
+			expectedStatus:   http.StatusOK,
+			expectedExpires:  time.Now().Add(30 * time.Minute),
+		},
+		{
+			name:             "Maximum lifetime reached",
+			originalIssuedAt: time.Now().Add(-2 * time.Hour),
+			expectedStatus:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Hour)
+			claims["orig_iat"] = tt.originalIssuedAt.Unix()
+
+			tokenString, err := auth.ClaimsToken(claims)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/token/refresh", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+			rr := httptest.NewRecorder()
+
+			mux.ServeHTTP(rr, req)
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var refreshed authentication.TokenResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &refreshed))
+
+			refreshedClaims, err := auth.ParseToken(refreshed.AccessToken)
+			require.NoError(t, err)
+
+			expirationTime, err := refreshedClaims.GetExpirationTime()
+			require.NoError(t, err)
+			assert.WithinDuration(t, tt.expectedExpires, expirationTime.Time, 2*time.Second)
+			assert.Equal(t, float64(tt.originalIssuedAt.Unix()), (*refreshedClaims)["orig_iat"])
+		})
+	}
+}
+
+func TestHandler_Logout(t *testing.T) {
+	mux := newTestMux()
+	token := login(t, mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	rr = httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
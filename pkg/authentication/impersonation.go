@@ -0,0 +1,217 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ImpersonationClaim is the claim that holds the reason of an impersonation token,
+// whose "act" claim identifies the impersonator.
+const ImpersonationClaim = "impersonation"
+
+// DefaultImpersonationDuration is the lifetime of impersonation tokens, when no duration is set.
+const DefaultImpersonationDuration = 15 * time.Minute
+
+// Impersonation events.
+const (
+	// ImpersonationIssued is the event of an issued impersonation token.
+	ImpersonationIssued = "issued"
+	// ImpersonationRequest is the event of a request made with an impersonation token.
+	ImpersonationRequest = "request"
+)
+
+// IdentityResolver returns the identity of a subject, such as its role.
+type IdentityResolver func(ctx context.Context, subject string) (Identity, error)
+
+// ImpersonationPolicy sets who can impersonate whom.
+//
+// Impersonators is the list of roles that can impersonate users. It defaults to the admin role.
+// Roles is the list of roles that can be impersonated. The admin role is never impersonated.
+// Duration is the lifetime of impersonation tokens, which defaults to DefaultImpersonationDuration.
+// Identities resolves the role of impersonated subjects.
+type ImpersonationPolicy struct {
+	Impersonators []string
+	Roles         []string
+	Duration      time.Duration
+	Identities    IdentityResolver
+}
+
+// ImpersonationEntry is the record of an impersonation token issuance, or of a request made with it.
+type ImpersonationEntry struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	Impersonator string    `json:"impersonator"`
+	Subject      string    `json:"subject"`
+	Role         string    `json:"role"`
+	Reason       string    `json:"reason"`
+	TokenID      string    `json:"token_id"`
+	Method       string    `json:"method,omitempty"`
+	Route        string    `json:"route,omitempty"`
+}
+
+// ImpersonationLog records impersonation entries.
+//
+// Record is called while serving requests, so it must not block.
+type ImpersonationLog interface {
+	Record(entry ImpersonationEntry)
+}
+
+// ImpersonationLogFunc is a function that implements ImpersonationLog.
+type ImpersonationLogFunc func(entry ImpersonationEntry)
+
+// Record calls the function.
+func (f ImpersonationLogFunc) Record(entry ImpersonationEntry) {
+	f(entry)
+}
+
+// defaultImpersonationLog writes the entries as JSON to the standard logger.
+func defaultImpersonationLog(entry ImpersonationEntry) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("JSON marshal error:", err)
+		return
+	}
+
+	log.Println("impersonation:", string(entryJSON))
+}
+
+type impersonationKey struct{}
+
+// ImpersonationFromContext returns the impersonation entry of a request made with an impersonation token.
+func ImpersonationFromContext(ctx context.Context) (ImpersonationEntry, bool) {
+	entry, ok := ctx.Value(impersonationKey{}).(ImpersonationEntry)
+
+	return entry, ok
+}
+
+// IsImpersonated reports whether the request was made with an impersonation token.
+func IsImpersonated(ctx context.Context) bool {
+	_, ok := ImpersonationFromContext(ctx)
+
+	return ok
+}
+
+// Impersonate issues a short-lived token of the target subject, on behalf of the admin of the given claims,
+// for the given reason. The "act" claim of the token identifies the admin.
+//
+// It fails with ErrImpersonationNotAllowed when the admin role can't impersonate, the target role can't be
+// impersonated, or the admin is already impersonating another user.
+func (m *Middleware) Impersonate(ctx context.Context, adminClaims Claims, targetSubject, reason string) (string, error) {
+	policy := m.impersonation
+
+	if policy.Identities == nil {
+		return "", fmt.Errorf("%w: impersonation isn't enabled", ErrImpersonationNotAllowed)
+	}
+
+	// The token is issued for the admin tenant, whose admin role can't be impersonated.
+	if adminClaims.Tenant != "" {
+		ctx = ContextWithTenant(ctx, adminClaims.Tenant)
+	}
+
+	s, err := m.contextScope(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	adminRole := s.adminRole
+
+	if targetSubject == "" || reason == "" {
+		return "", ErrInvalidRequest
+	}
+
+	impersonators := policy.Impersonators
+	if len(impersonators) == 0 {
+		impersonators = []string{adminRole}
+	}
+
+	if adminClaims.Role == "" || !slices.Contains(impersonators, adminClaims.Role) ||
+		adminClaims.Impersonation != "" || targetSubject == adminClaims.Subject {
+		return "", ErrImpersonationNotAllowed
+	}
+
+	target, err := policy.Identities(ctx, targetSubject)
+	if err != nil {
+		return "", fmt.Errorf("identity resolve failed: %v", err)
+	}
+
+	if target.Role == "" || target.Role == adminRole || !slices.Contains(policy.Roles, target.Role) {
+		return "", ErrImpersonationNotAllowed
+	}
+
+	duration := policy.Duration
+	if duration <= 0 {
+		duration = DefaultImpersonationDuration
+	}
+
+	claims := NewMapClaims(target.Subject, adminClaims.Issuer, adminClaims.Audience, target.Role, duration)
+	claims[ActorClaim] = (&Actor{Subject: adminClaims.Subject}).claim()
+	claims[ImpersonationClaim] = reason
+
+	// The user didn't authenticate, so the token doesn't claim it did.
+	delete(claims, "auth_time")
+
+	tokenString, err := s.auth.ClaimsToken(claims)
+	if err != nil {
+		return "", fmt.Errorf("impersonation failed: %v", err)
+	}
+
+	id := claims["id"].(string)
+
+	if err = m.saveSession(ctx, id, target.Subject, tokenString, duration); err != nil {
+		return "", err
+	}
+
+	m.impersonationLog.Record(ImpersonationEntry{
+		Time:         time.Now(),
+		Event:        ImpersonationIssued,
+		Impersonator: adminClaims.Subject,
+		Subject:      target.Subject,
+		Role:         target.Role,
+		Reason:       reason,
+		TokenID:      id,
+	})
+
+	return tokenString, nil
+}
+
+// impersonated flags the requests made with impersonation tokens in context, and records them.
+func (m *Middleware) impersonated(ctx context.Context, r *http.Request, claims *jwt.MapClaims) context.Context {
+	reason, ok := (*claims)[ImpersonationClaim].(string)
+	if !ok {
+		return ctx
+	}
+
+	subject, _ := (*claims)["sub"].(string)
+	role, _ := (*claims)["role"].(string)
+	id, _ := (*claims)["id"].(string)
+
+	// Exchanged impersonation tokens have the services acting on their behalf before the impersonator,
+	// so the impersonator is the first actor of the delegation chain.
+	var impersonator string
+	if chain := parseActor((*claims)[ActorClaim]).Chain(); len(chain) > 0 {
+		impersonator = chain[len(chain)-1]
+	}
+
+	entry := ImpersonationEntry{
+		Time:         time.Now(),
+		Event:        ImpersonationRequest,
+		Impersonator: impersonator,
+		Subject:      subject,
+		Role:         role,
+		Reason:       reason,
+		TokenID:      id,
+		Method:       r.Method,
+		Route:        r.URL.Path,
+	}
+
+	m.impersonationLog.Record(entry)
+
+	return context.WithValue(ctx, impersonationKey{}, entry)
+}
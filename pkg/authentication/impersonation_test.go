@@ -0,0 +1,303 @@
+package authentication_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+type impersonationLog struct {
+	mu      sync.Mutex
+	entries []authentication.ImpersonationEntry
+}
+
+func (l *impersonationLog) Record(entry authentication.ImpersonationEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+}
+
+func (l *impersonationLog) Entries() []authentication.ImpersonationEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]authentication.ImpersonationEntry(nil), l.entries...)
+}
+
+var identities = map[string]string{
+	"user-1":    "user",
+	"support-1": "support",
+	"admin-2":   "admin",
+}
+
+func resolveIdentity(_ context.Context, subject string) (authentication.Identity, error) {
+	role, ok := identities[subject]
+	if !ok {
+		return authentication.Identity{}, errors.New("user not found")
+	}
+
+	return authentication.Identity{Subject: subject, Role: role}, nil
+}
+
+func newImpersonationMiddleware(
+	sessions authentication.SessionStore,
+	impersonations *impersonationLog,
+) *authentication.Middleware {
+	return authentication.NewMiddleware(
+		authentication.Default("secret", 3600),
+		authentication.WithAdminRole("admin"),
+		authentication.WithSessionStore(sessions),
+		authentication.WithImpersonation(authentication.ImpersonationPolicy{
+			Roles:      []string{"user", "admin"},
+			Duration:   5 * time.Minute,
+			Identities: resolveIdentity,
+		}, impersonations),
+		authentication.WithTokenExchange(authentication.ExchangePolicy{
+			Clients: map[string]authentication.ExchangeClient{
+				"service-a": {Audiences: []string{"orders"}, Role: "service"},
+			},
+		}),
+	)
+}
+
+func TestMiddleware_Impersonate(t *testing.T) {
+	adminClaims := authentication.Claims{Subject: "admin-1", Issuer: "issuer", Audience: "audience", Role: "admin"}
+
+	tests := []struct {
+		name          string
+		claims        authentication.Claims
+		targetSubject string
+		reason        string
+		expectedErr   error
+	}{
+		{
+			name:          "Admin impersonates user",
+			claims:        adminClaims,
+			targetSubject: "user-1",
+			reason:        "ticket 42",
+		},
+		{
+			name:          "Admin target is refused",
+			claims:        adminClaims,
+			targetSubject: "admin-2",
+			reason:        "ticket 42",
+			expectedErr:   authentication.ErrImpersonationNotAllowed,
+		},
+		{
+			name:          "Target role not allowed",
+			claims:        adminClaims,
+			targetSubject: "support-1",
+			reason:        "ticket 42",
+			expectedErr:   authentication.ErrImpersonationNotAllowed,
+		},
+		{
+			name:          "Role can't impersonate",
+			claims:        authentication.Claims{Subject: "support-1", Role: "support"},
+			targetSubject: "user-1",
+			reason:        "ticket 42",
+			expectedErr:   authentication.ErrImpersonationNotAllowed,
+		},
+		{
+			name: "Impersonation can't be chained",
+			claims: authentication.Claims{
+				Subject:       "user-1",
+				Role:          "admin",
+				Actor:         &authentication.Actor{Subject: "admin-1"},
+				Impersonation: "ticket 41",
+			},
+			targetSubject: "user-1",
+			reason:        "ticket 42",
+			expectedErr:   authentication.ErrImpersonationNotAllowed,
+		},
+		{
+			name:          "Self impersonation",
+			claims:        adminClaims,
+			targetSubject: "admin-1",
+			reason:        "ticket 42",
+			expectedErr:   authentication.ErrImpersonationNotAllowed,
+		},
+		{
+			name:          "Missing reason",
+			claims:        adminClaims,
+			targetSubject: "user-1",
+			expectedErr:   authentication.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impersonations := &impersonationLog{}
+			middleware := newImpersonationMiddleware(authtest.NewSessionStore(), impersonations)
+
+			tokenString, err := middleware.Impersonate(context.Background(), tt.claims, tt.targetSubject, tt.reason)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				assert.Empty(t, impersonations.Entries())
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			entries := impersonations.Entries()
+			require.Len(t, entries, 1)
+			assert.Equal(t, authentication.ImpersonationIssued, entries[0].Event)
+			assert.Equal(t, "admin-1", entries[0].Impersonator)
+			assert.Equal(t, tt.targetSubject, entries[0].Subject)
+			assert.Equal(t, tt.reason, entries[0].Reason)
+
+			auth := authentication.Default("secret", 3600)
+
+			claims, err := auth.ParseToken(tokenString)
+			require.NoError(t, err)
+
+			expirationTime, err := claims.GetExpirationTime()
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(5*time.Minute), expirationTime.Time, 5*time.Second)
+			assert.NotContains(t, *claims, "auth_time")
+		})
+	}
+}
+
+func TestMiddleware_ImpersonateDisabled(t *testing.T) {
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600), authentication.WithAdminRole("admin"))
+
+	_, err := middleware.Impersonate(context.Background(),
+		authentication.Claims{Subject: "admin-1", Role: "admin"}, "user-1", "ticket 42")
+	assert.ErrorIs(t, err, authentication.ErrImpersonationNotAllowed)
+}
+
+func TestMiddleware_ImpersonatedRequest(t *testing.T) {
+	impersonations := &impersonationLog{}
+	sessions := authtest.NewSessionStore()
+	middleware := newImpersonationMiddleware(sessions, impersonations)
+
+	adminClaims := authentication.Claims{Subject: "admin-1", Issuer: "issuer", Audience: "audience", Role: "admin"}
+
+	tokenString, err := middleware.Impersonate(context.Background(), adminClaims, "user-1", "ticket 42")
+	require.NoError(t, err)
+	assert.Len(t, sessions.Sessions(), 1)
+
+	var entry authentication.ImpersonationEntry
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+
+		entry, ok = authentication.ImpersonationFromContext(r.Context())
+		assert.True(t, ok)
+		assert.True(t, authentication.IsImpersonated(r.Context()))
+
+		claims, err := middleware.GetClaims(r.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+		assert.Equal(t, "ticket 42", claims.Impersonation)
+		assert.Equal(t, []string{"admin-1"}, claims.ActorChain())
+
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, authentication.ImpersonationRequest, entry.Event)
+	assert.Equal(t, "admin-1", entry.Impersonator)
+	assert.Equal(t, "user-1", entry.Subject)
+	assert.Equal(t, "/orders", entry.Route)
+
+	entries := impersonations.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, entry, entries[1])
+
+	// Requests made with regular tokens aren't flagged.
+	userToken, err := middleware.Login(context.Background(), "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	rr, _, err = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), userToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Len(t, impersonations.Entries(), 2)
+}
+
+func TestHandler_RefreshImpersonation(t *testing.T) {
+	middleware := newImpersonationMiddleware(authtest.NewSessionStore(), &impersonationLog{})
+
+	adminClaims := authentication.Claims{Subject: "admin-1", Issuer: "issuer", Audience: "audience", Role: "admin"}
+
+	tokenString, err := middleware.Impersonate(context.Background(), adminClaims, "user-1", "ticket 42")
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	authentication.NewHandler(middleware, mockAuthenticator{}, "issuer", "audience").Register(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestMiddleware_ImpersonationNotRenewed(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithAdminRole("admin"),
+		authentication.WithRenewal(authentication.Renewal{Window: time.Hour}),
+		authentication.WithImpersonation(authentication.ImpersonationPolicy{
+			Roles:      []string{"user"},
+			Identities: resolveIdentity,
+		}, authentication.ImpersonationLogFunc(func(authentication.ImpersonationEntry) {})),
+	)
+
+	tokenString, err := middleware.Impersonate(context.Background(),
+		authentication.Claims{Subject: "admin-1", Role: "admin"}, "user-1", "ticket 42")
+	require.NoError(t, err)
+
+	rr, _, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), tokenString)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Empty(t, rr.Header().Get(authentication.DefaultRenewalHeader))
+}
+
+func TestMiddleware_ExchangedImpersonation(t *testing.T) {
+	ctx := context.Background()
+	impersonations := &impersonationLog{}
+	middleware := newImpersonationMiddleware(authtest.NewSessionStore(), impersonations)
+
+	tokenString, err := middleware.Impersonate(ctx,
+		authentication.Claims{Subject: "admin-1", Role: "admin"}, "user-1", "ticket 42")
+	require.NoError(t, err)
+
+	serviceToken, err := middleware.Login(ctx, "service-a", "issuer", "audience", "service")
+	require.NoError(t, err)
+
+	exchanged, _, err := middleware.Exchange(ctx, authentication.ExchangeRequest{
+		SubjectToken: tokenString,
+		ActorToken:   serviceToken,
+		Audience:     "orders",
+		Scope:        []string{"orders:read"},
+	})
+	require.NoError(t, err)
+
+	rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/orders", nil), exchanged)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Equal(t, "ticket 42", claims.Impersonation)
+	assert.Equal(t, []string{"service-a", "admin-1"}, claims.ActorChain())
+
+	entries := impersonations.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "admin-1", entries[1].Impersonator)
+}
@@ -0,0 +1,146 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// KeyManagementRSAOAEP is the RSAES OAEP key management algorithm, with SHA-1.
+	KeyManagementRSAOAEP = string(jose.RSA_OAEP)
+	// KeyManagementRSAOAEP256 is the RSAES OAEP key management algorithm, with SHA-256.
+	KeyManagementRSAOAEP256 = string(jose.RSA_OAEP_256)
+	// KeyManagementECDHES is the Elliptic Curve Diffie-Hellman Ephemeral Static key agreement algorithm.
+	KeyManagementECDHES = string(jose.ECDH_ES)
+	// ContentEncryptionA256GCM is the AES-256 GCM content encryption algorithm.
+	ContentEncryptionA256GCM = string(jose.A256GCM)
+
+	jweSegments       = 5
+	nestedContentType = "JWT"
+)
+
+// Encryption configures nested signed-then-encrypted tokens (JWE), with A256GCM content encryption.
+// Tokens are encrypted and decrypted with go-jose.
+//
+// KeyManagement is the JWE key management algorithm: RSA-OAEP, RSA-OAEP-256 or ECDH-ES.
+// PublicKey is the recipient key used to encrypt tokens: *rsa.PublicKey, *ecdsa.PublicKey or *ecdh.PublicKey.
+// PrivateKey is the recipient key used to decrypt tokens: *rsa.PrivateKey, *ecdsa.PrivateKey or *ecdh.PrivateKey.
+type Encryption struct {
+	KeyManagement string
+	PublicKey     crypto.PublicKey
+	PrivateKey    crypto.PrivateKey
+}
+
+// IsEncrypted reports whether a token is in JWE compact serialization.
+func IsEncrypted(tokenString string) bool {
+	return strings.Count(tokenString, ".") == jweSegments-1
+}
+
+// Encrypt encrypts a signed token in JWE compact serialization.
+func (e *Encryption) Encrypt(tokenString string) (string, error) {
+	if err := e.checkKeyManagement(); err != nil {
+		return "", err
+	}
+
+	key, err := joseKey(e.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	encrypter, err := jose.NewEncrypter(
+		jose.A256GCM,
+		jose.Recipient{Algorithm: jose.KeyAlgorithm(e.KeyManagement), Key: key},
+		(&jose.EncrypterOptions{}).WithContentType(nestedContentType),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEncryption, err)
+	}
+
+	object, err := encrypter.Encrypt([]byte(tokenString))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEncryption, err)
+	}
+
+	return object.CompactSerialize()
+}
+
+// Decrypt decrypts a token in JWE compact serialization and returns the nested signed token.
+func (e *Encryption) Decrypt(tokenString string) (string, error) {
+	if err := e.checkKeyManagement(); err != nil {
+		return "", err
+	}
+
+	// Only the configured algorithms are accepted, so a token can't choose a weaker one.
+	object, err := jose.ParseEncryptedCompact(tokenString,
+		[]jose.KeyAlgorithm{jose.KeyAlgorithm(e.KeyManagement)}, []jose.ContentEncryption{jose.A256GCM})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEncryption, err)
+	}
+
+	key, err := joseKey(e.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := object.Decrypt(key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEncryption, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// checkKeyManagement checks the key management algorithm is a supported one.
+func (e *Encryption) checkKeyManagement() error {
+	switch e.KeyManagement {
+	case KeyManagementRSAOAEP, KeyManagementRSAOAEP256, KeyManagementECDHES:
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported key management %q", ErrInvalidEncryption, e.KeyManagement)
+	}
+}
+
+var ecdhCurves = map[ecdh.Curve]elliptic.Curve{
+	ecdh.P256(): elliptic.P256(),
+	ecdh.P384(): elliptic.P384(),
+	ecdh.P521(): elliptic.P521(),
+}
+
+// joseKey returns a key as go-jose accepts it, which doesn't support crypto/ecdh keys,
+// so they are converted to crypto/ecdsa ones.
+func joseKey(key any) (any, error) {
+	switch k := key.(type) {
+	case *ecdh.PublicKey:
+		curve, ok := ecdhCurves[k.Curve()]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported curve", ErrInvalidEncryption)
+		}
+
+		publicKey, err := ecdsa.ParseUncompressedPublicKey(curve, k.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEncryption, err)
+		}
+
+		return publicKey, nil
+	case *ecdh.PrivateKey:
+		curve, ok := ecdhCurves[k.Curve()]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported curve", ErrInvalidEncryption)
+		}
+
+		privateKey, err := ecdsa.ParseRawPrivateKey(curve, k.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEncryption, err)
+		}
+
+		return privateKey, nil
+	default:
+		return key, nil
+	}
+}
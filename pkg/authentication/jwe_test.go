@@ -0,0 +1,172 @@
+package authentication_test
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestAuth_EncryptedToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	ecdhKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		keyManagement string
+		publicKey     crypto.PublicKey
+		privateKey    crypto.PrivateKey
+	}{
+		{
+			name:          "RSA-OAEP",
+			keyManagement: authentication.KeyManagementRSAOAEP,
+			publicKey:     &rsaKey.PublicKey,
+			privateKey:    rsaKey,
+		},
+		{
+			name:          "RSA-OAEP-256",
+			keyManagement: authentication.KeyManagementRSAOAEP256,
+			publicKey:     &rsaKey.PublicKey,
+			privateKey:    rsaKey,
+		},
+		{
+			name:          "ECDH-ES P-256",
+			keyManagement: authentication.KeyManagementECDHES,
+			publicKey:     &p256Key.PublicKey,
+			privateKey:    p256Key,
+		},
+		{
+			name:          "ECDH-ES P-384",
+			keyManagement: authentication.KeyManagementECDHES,
+			publicKey:     &p384Key.PublicKey,
+			privateKey:    p384Key,
+		},
+		{
+			name:          "ECDH-ES crypto/ecdh key",
+			keyManagement: authentication.KeyManagementECDHES,
+			publicKey:     ecdhKey.PublicKey(),
+			privateKey:    ecdhKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := authentication.Default("secret", 3600)
+			auth.Encryption = &authentication.Encryption{
+				KeyManagement: tt.keyManagement,
+				PublicKey:     tt.publicKey,
+				PrivateKey:    tt.privateKey,
+			}
+
+			tokenString, err := auth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "aud", "user", time.Hour))
+			require.NoError(t, err)
+			assert.True(t, authentication.IsEncrypted(tokenString))
+
+			claims, err := auth.ParseToken(tokenString)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", (*claims)["sub"])
+
+			// Claims can't be read without decrypting the token.
+			_, _, err = jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+			assert.Error(t, err)
+
+			// Signed only tokens are rejected, even when their signature is valid.
+			signed := auth
+			signed.Encryption = nil
+
+			signedToken, err := signed.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "aud", "user", time.Hour))
+			require.NoError(t, err)
+
+			_, err = auth.ParseToken(signedToken)
+			assert.ErrorIs(t, err, authentication.ErrInvalidEncryption)
+		})
+	}
+}
+
+func TestEncryption_Decrypt(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encryption := &authentication.Encryption{
+		KeyManagement: authentication.KeyManagementECDHES,
+		PublicKey:     &key.PublicKey,
+		PrivateKey:    key,
+	}
+
+	tokenString, err := encryption.Encrypt("header.payload.signature")
+	require.NoError(t, err)
+
+	segments := strings.Split(tokenString, ".")
+
+	tests := []struct {
+		name       string
+		token      string
+		encryption *authentication.Encryption
+	}{
+		{
+			name:       "Tampered ciphertext",
+			token:      strings.Join([]string{segments[0], segments[1], segments[2], "AAAA" + segments[3][4:], segments[4]}, "."),
+			encryption: encryption,
+		},
+		{
+			name:       "Tampered header",
+			token:      strings.Join([]string{"e30", segments[1], segments[2], segments[3], segments[4]}, "."),
+			encryption: encryption,
+		},
+		{
+			name:  "Wrong key",
+			token: tokenString,
+			encryption: &authentication.Encryption{
+				KeyManagement: authentication.KeyManagementECDHES,
+				PrivateKey:    otherKey,
+			},
+		},
+		{
+			name:  "Unexpected algorithm",
+			token: tokenString,
+			encryption: &authentication.Encryption{
+				KeyManagement: authentication.KeyManagementRSAOAEP,
+				PrivateKey:    key,
+			},
+		},
+		{
+			name:       "Signed only token",
+			token:      "header.payload.signature",
+			encryption: encryption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.encryption.Decrypt(tt.token)
+			assert.ErrorIs(t, err, authentication.ErrInvalidEncryption)
+		})
+	}
+
+	payload, err := encryption.Decrypt(tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, "header.payload.signature", payload)
+}
@@ -0,0 +1,73 @@
+// Package mfa provides multi-factor authentication with TOTP codes and recovery codes,
+// and upgrades the tokens of users who complete it.
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+var (
+	// ErrInvalidCode is returned when a code doesn't match.
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrCodeReused is returned when a valid code was already used.
+	ErrCodeReused = errors.New("code already used")
+	// ErrInvalidSecret is returned when a TOTP secret isn't valid base32.
+	ErrInvalidSecret = errors.New("invalid secret")
+)
+
+// Upgrade issues a new token for the current claims, after a successful verification.
+//
+// The new token "amr" claim adds the otp method to the current ones, which default to pwd, and its "auth_time"
+// claim is the verification time. The current token is revoked, and its session metadata and start are kept, so
+// the session maximum lifetime still applies.
+// As with refreshes, scoped, exchanged and impersonation tokens aren't upgraded, and fail with
+// authentication.ErrForbidden, since the new token would drop their restrictions.
+func Upgrade(ctx context.Context, jwtMiddleware authentication.JWT, claims authentication.Claims) (string, error) {
+	if claims.Scope != "" || claims.Actor != nil || claims.Impersonation != "" {
+		return "", authentication.ErrForbidden
+	}
+
+	userAuth := claims.UserAuthentication()
+
+	if len(userAuth.AMR) == 0 {
+		userAuth.AMR = []string{authentication.MethodPassword}
+	}
+
+	if !slices.Contains(userAuth.AMR, authentication.MethodOTP) {
+		userAuth.AMR = append(slices.Clone(userAuth.AMR), authentication.MethodOTP)
+	}
+
+	userAuth.Time = time.Now()
+
+	metadata, _ := authentication.SessionMetadataFromContext(ctx)
+	metadata.RenewedFrom = claims.ID
+
+	ctx = jwtMiddleware.Logout(ctx)
+	ctx = authentication.ContextWithSessionMetadata(ctx, metadata)
+
+	// The new token is issued for the current token tenant.
+	if claims.Tenant != "" {
+		ctx = authentication.ContextWithTenant(ctx, claims.Tenant)
+	}
+
+	originalIssuedAt := claims.OriginalIssuedAt
+	if originalIssuedAt == 0 {
+		originalIssuedAt = claims.IssuedAt
+	}
+
+	ctx = authentication.ContextWithOriginalIssuedAt(ctx, time.Unix(originalIssuedAt, 0))
+	ctx = authentication.ContextWithUserAuthentication(ctx, userAuth)
+
+	tokenString, err := jwtMiddleware.Login(ctx, claims.Subject, claims.Issuer, claims.Audience, claims.Role)
+	if err != nil {
+		return "", fmt.Errorf("token upgrade failed: %v", err)
+	}
+
+	return tokenString, nil
+}
@@ -0,0 +1,188 @@
+package mfa
+
+import (
+	"context"
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// RFC 6238 test vectors, with 8 digit codes.
+func TestTOTP_Code(t *testing.T) {
+	secrets := map[string]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	tests := []struct {
+		unix         int64
+		algorithm    string
+		expectedCode string
+	}{
+		{unix: 59, algorithm: AlgorithmSHA1, expectedCode: "94287082"},
+		{unix: 59, algorithm: AlgorithmSHA256, expectedCode: "46119246"},
+		{unix: 59, algorithm: AlgorithmSHA512, expectedCode: "90693936"},
+		{unix: 1111111109, algorithm: AlgorithmSHA1, expectedCode: "07081804"},
+		{unix: 1234567890, algorithm: AlgorithmSHA256, expectedCode: "91819424"},
+		{unix: 20000000000, algorithm: AlgorithmSHA512, expectedCode: "47863826"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm+"/"+tt.expectedCode, func(t *testing.T) {
+			totp := NewTOTP("issuer", nil)
+			totp.Algorithm = tt.algorithm
+			totp.Digits = 8
+
+			secret := base32.StdEncoding.EncodeToString([]byte(secrets[tt.algorithm]))
+
+			code, err := totp.Code(secret, time.Unix(tt.unix, 0))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, code)
+		})
+	}
+}
+
+func TestTOTP_URI(t *testing.T) {
+	totp := NewTOTP("Example Co", nil)
+
+	uri, err := url.Parse(totp.URI("JBSWY3DPEHPK3PXP", "john@example.com"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Example Co:john@example.com", uri.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {"JBSWY3DPEHPK3PXP"},
+		"issuer":    {"Example Co"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, uri.Query())
+}
+
+func TestTOTP_Verify(t *testing.T) {
+	ctx := context.Background()
+	totp := NewTOTP("issuer", nil)
+
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	previous, err := totp.Code(secret, time.Now().Add(-totp.Period))
+	require.NoError(t, err)
+
+	tooOld, err := totp.Code(secret, time.Now().Add(-3*totp.Period))
+	require.NoError(t, err)
+
+	assert.NoError(t, totp.Verify(ctx, "user-1", secret, previous))
+	assert.ErrorIs(t, totp.Verify(ctx, "user-1", secret, previous), ErrCodeReused)
+	assert.ErrorIs(t, totp.Verify(ctx, "user-1", secret, tooOld), ErrInvalidCode)
+	assert.ErrorIs(t, totp.Verify(ctx, "user-1", "not base32!", previous), ErrInvalidSecret)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	replay := authentication.NewMemoryReplayStore()
+
+	codes, hashes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	assert.Regexp(t, `^[2-9A-HJ-NP-Z]{5}-[2-9A-HJ-NP-Z]{5}$`, codes[0])
+
+	index, err := VerifyRecoveryCode(ctx, replay, "user-1", codes[3], hashes)
+	require.NoError(t, err)
+	assert.Equal(t, 3, index)
+
+	_, err = VerifyRecoveryCode(ctx, replay, "user-1", codes[3], hashes)
+	assert.ErrorIs(t, err, ErrCodeReused)
+
+	_, err = VerifyRecoveryCode(ctx, replay, "user-1", "AAAAA-AAAAA", hashes)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	// Used codes are consumed permanently, even if their hash is kept.
+	ok, err := replay.Use(ctx, "recovery:user-1:"+hashes[3], time.Hour)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestUpgrade(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	middleware := authentication.NewMiddleware(auth)
+
+	sessionStart := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+
+	ctx := authentication.ContextWithUserAuthentication(context.Background(), authentication.UserAuthentication{
+		AMR:  []string{authentication.MethodPassword},
+		Time: time.Now().Add(-time.Hour),
+	})
+	ctx = authentication.ContextWithOriginalIssuedAt(ctx, sessionStart)
+
+	tokenString, err := middleware.Login(ctx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	var upgraded string
+
+	req := httptest.NewRequest(http.MethodPost, "/mfa/verify", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		claims, err := middleware.GetClaims(r.Context())
+		require.NoError(t, err)
+
+		upgraded, err = Upgrade(r.Context(), middleware, claims)
+		require.NoError(t, err)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	claims, err := auth.ParseToken(upgraded)
+	require.NoError(t, err)
+
+	assert.Equal(t, []any{authentication.MethodPassword, authentication.MethodOTP}, (*claims)["amr"])
+	assert.Equal(t, "user-1", (*claims)["sub"])
+	assert.InDelta(t, time.Now().Unix(), (*claims)["auth_time"], 5)
+
+	// The session start is kept, so upgrades don't extend the session maximum lifetime.
+	assert.Equal(t, float64(sessionStart.Unix()), (*claims)["orig_iat"])
+}
+
+func TestUpgrade_Restricted(t *testing.T) {
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600))
+
+	tests := []struct {
+		name   string
+		claims authentication.Claims
+	}{
+		{
+			name:   "Scoped token",
+			claims: authentication.Claims{Subject: "user-1", Role: "user", Scope: "orders:read"},
+		},
+		{
+			name:   "Exchanged token",
+			claims: authentication.Claims{Subject: "user-1", Role: "user", Actor: &authentication.Actor{Subject: "service-1"}},
+		},
+		{
+			name: "Impersonation token",
+			claims: authentication.Claims{
+				Subject:       "user-1",
+				Role:          "user",
+				Actor:         &authentication.Actor{Subject: "admin-1"},
+				Impersonation: "ticket 4242",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Upgrade(context.Background(), middleware, tt.claims)
+			assert.ErrorIs(t, err, authentication.ErrForbidden)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+const (
+	// recoveryAlphabet leaves out characters that are easily confused, such as 0 and O, or 1 and I.
+	recoveryAlphabet   = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	recoveryCodeLength = 10
+)
+
+// GenerateRecoveryCodes returns the given number of random recovery codes, formatted as XXXXX-XXXXX,
+// and their hashes.
+//
+// Codes are shown to the user once, while only their hashes should be stored.
+func GenerateRecoveryCodes(count int) ([]string, []string, error) {
+	codes := make([]string, count)
+	hashes := make([]string, count)
+
+	random := make([]byte, recoveryCodeLength)
+
+	for i := range codes {
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+
+		var code strings.Builder
+
+		for j, value := range random {
+			if j == recoveryCodeLength/2 {
+				code.WriteByte('-')
+			}
+
+			// The alphabet length divides 256, so characters are evenly distributed.
+			code.WriteByte(recoveryAlphabet[int(value)%len(recoveryAlphabet)])
+		}
+
+		codes[i] = code.String()
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code, ignoring case, spaces and dashes.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyRecoveryCode checks a recovery code of the subject against its stored hashes,
+// and returns the index of the matching hash.
+//
+// The matching hash should then be removed by the application. Used codes are consumed permanently in the replay
+// store, so further and concurrent uses of the same code are rejected with ErrCodeReused.
+func VerifyRecoveryCode(
+	ctx context.Context,
+	replay authentication.ReplayStore,
+	subject, code string,
+	hashes []string,
+) (int, error) {
+	hash := HashRecoveryCode(code)
+	index := -1
+
+	for i := range hashes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(hashes[i])) == 1 {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		return -1, ErrInvalidCode
+	}
+
+	// Used codes are recorded permanently, so a code is rejected even if the application keeps its hash.
+	ok, err := replay.Use(ctx, fmt.Sprintf("recovery:%s:%s", subject, hash), 0)
+	if err != nil {
+		return -1, fmt.Errorf("replay store error: %v", err)
+	}
+
+	if !ok {
+		return -1, ErrCodeReused
+	}
+
+	return index, nil
+}
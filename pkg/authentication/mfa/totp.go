@@ -0,0 +1,237 @@
+package mfa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // RFC 6238 uses HMAC-SHA1 by default, which authenticator apps expect.
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// TOTP algorithms, as named in otpauth URIs.
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+)
+
+const (
+	defaultDigits = 6
+	defaultPeriod = 30 * time.Second
+	defaultSkew   = 1
+	secretSize    = 20
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP generates and verifies RFC 6238 time-based one-time passwords.
+//
+// Issuer is the service name shown by authenticator apps.
+// Algorithm is the HMAC algorithm, which defaults to AlgorithmSHA1.
+// Digits is the code length, which defaults to 6.
+// Period is the time step of each code, which defaults to 30 seconds.
+// Skew is the number of time steps accepted before and after the current one, to tolerate clock drift.
+// Replay records the used codes, so each code is only accepted once.
+type TOTP struct {
+	Issuer    string
+	Algorithm string
+	Digits    int
+	Period    time.Duration
+	Skew      int
+	Replay    authentication.ReplayStore
+}
+
+// NewTOTP is a TOTP constructor, with 6 digit codes, a 30 second period and one time step of drift tolerance.
+//
+// replay records the used codes. It defaults to an in-memory store when nil.
+func NewTOTP(issuer string, replay authentication.ReplayStore) *TOTP {
+	if replay == nil {
+		replay = authentication.NewMemoryReplayStore()
+	}
+
+	return &TOTP{
+		Issuer:    issuer,
+		Algorithm: AlgorithmSHA1,
+		Digits:    defaultDigits,
+		Period:    defaultPeriod,
+		Skew:      defaultSkew,
+		Replay:    replay,
+	}
+}
+
+// GenerateSecret returns a random 160 bit secret, encoded as base32 without padding.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return secretEncoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth URI of a secret, which authenticator apps import, usually from a QR code.
+func (t *TOTP) URI(secret, account string) string {
+	label := url.PathEscape(account)
+	if t.Issuer != "" {
+		label = url.PathEscape(t.Issuer) + ":" + label
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("algorithm", t.algorithm())
+	query.Set("digits", strconv.Itoa(t.digits()))
+	query.Set("period", strconv.Itoa(int(t.period().Seconds())))
+
+	if t.Issuer != "" {
+		query.Set("issuer", t.Issuer)
+	}
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code returns the code of a secret at the given time.
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return t.code(key, t.counter(at))
+}
+
+// Verify checks a code of the subject secret, within the drift tolerance.
+//
+// It returns ErrInvalidCode when the code doesn't match, and ErrCodeReused when it was already used.
+func (t *TOTP) Verify(ctx context.Context, subject, secret, code string) error {
+	if t.Replay == nil {
+		return fmt.Errorf("TOTP replay store isn't set")
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	current := t.counter(time.Now())
+
+	for counter := current - int64(t.Skew); counter <= current+int64(t.Skew); counter++ {
+		expected, err := t.code(key, counter)
+		if err != nil {
+			return err
+		}
+
+		if !hmac.Equal([]byte(expected), []byte(code)) {
+			continue
+		}
+
+		// A code is valid for the whole drift tolerance, so it's recorded for as long.
+		ttl := time.Duration(2*t.Skew+1) * t.period()
+
+		ok, err := t.Replay.Use(ctx, fmt.Sprintf("totp:%s:%d", subject, counter), ttl)
+		if err != nil {
+			return fmt.Errorf("replay store error: %v", err)
+		}
+
+		if !ok {
+			return ErrCodeReused
+		}
+
+		return nil
+	}
+
+	return ErrInvalidCode
+}
+
+// code computes the RFC 4226 HOTP value of a counter.
+func (t *TOTP) code(key []byte, counter int64) (string, error) {
+	newHash, err := t.hash()
+	if err != nil {
+		return "", err
+	}
+
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter)) //nolint:gosec // Counters of Unix times are positive.
+
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, from the offset in the last 4 bits.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	digits := t.digits()
+
+	modulo := uint32(1)
+	for range digits {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%modulo), nil
+}
+
+func (t *TOTP) counter(at time.Time) int64 {
+	return at.Unix() / int64(t.period().Seconds())
+}
+
+func (t *TOTP) hash() (func() hash.Hash, error) {
+	switch t.algorithm() {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported TOTP algorithm: %s", t.Algorithm)
+	}
+}
+
+func (t *TOTP) algorithm() string {
+	if t.Algorithm == "" {
+		return AlgorithmSHA1
+	}
+
+	return t.Algorithm
+}
+
+func (t *TOTP) digits() int {
+	if t.Digits <= 0 {
+		return defaultDigits
+	}
+
+	return t.Digits
+}
+
+func (t *TOTP) period() time.Duration {
+	if t.Period < time.Second {
+		return defaultPeriod
+	}
+
+	return t.Period
+}
+
+// decodeSecret decodes a base32 secret, ignoring case, spaces and padding.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+
+	key, err := secretEncoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
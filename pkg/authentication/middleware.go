@@ -0,0 +1,620 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrorRenderer writes an authentication error response.
+type ErrorRenderer func(w http.ResponseWriter, r *http.Request, err error)
+
+// Middleware is a JWT authentication middleware, that validates request tokens and checks role permissions.
+//
+// It implements JWT, and it is configured with functional options.
+// Its authorization rules are held in a Policy, which can be safely replaced while serving requests.
+type Middleware struct {
+	auditSink          AuditSink
+	auth               Auth
+	contextKey         any
+	enricher           ClaimsEnricher
+	errorRenderer      ErrorRenderer
+	exchange           ExchangePolicy
+	extractor          TokenExtractor
+	impersonation      ImpersonationPolicy
+	impersonationLog   ImpersonationLog
+	invalidTokenAction InvalidTokenAction
+	policy             atomic.Pointer[compiledPolicy]
+	renewal            Renewal
+	sessions           SessionStore
+	stepUpRules        []StepUpRule
+	stepUpTree         *prefixTree[[]StepUpRule]
+	tenantExtractor    TenantExtractor
+	tenants            TenantResolver
+	successors         *successors
+	tokenCache         *tokenCache
+	urlSigner          *URLSigner
+}
+
+// NewMiddleware is a Middleware constructor.
+//
+// auth holds the token signing and verification settings.
+// options configure the middleware, which verifies tokens sent as Authorization Bearer tokens by default.
+func NewMiddleware(auth Auth, options ...Option) *Middleware {
+	m := &Middleware{
+		auth:             auth,
+		contextKey:       auth.ClaimsKey,
+		errorRenderer:    DefaultErrorRenderer,
+		impersonationLog: ImpersonationLogFunc(defaultImpersonationLog),
+		successors:       newSuccessors(),
+	}
+
+	m.policy.Store(Policy{}.compile())
+
+	for _, option := range options {
+		option(m)
+	}
+
+	m.auth.keyID = verificationKeyID(m.auth.verificationKey())
+
+	if m.extractor == nil {
+		m.extractor = TokenFromBearer()
+
+		if m.renewal.Cookie != "" {
+			m.extractor = TokenFromAny(TokenFromBearer(), TokenFromCookie(m.renewal.Cookie))
+		}
+	}
+
+	return m
+}
+
+// DefaultErrorRenderer writes the error message as JSON, with an unauthorized status, or a forbidden status for
+// ErrForbidden. Step-up errors also set the WWW-Authenticate challenge header.
+func DefaultErrorRenderer(w http.ResponseWriter, _ *http.Request, err error) {
+	var stepUpErr *StepUpError
+	if errors.As(err, &stepUpErr) {
+		w.Header().Set("WWW-Authenticate", stepUpErr.Challenge())
+	}
+
+	status := http.StatusUnauthorized
+	if errors.Is(err, ErrForbidden) {
+		status = http.StatusForbidden
+	}
+
+	writeError(w, status, err)
+}
+
+// scope holds the settings used to authenticate one request, which depend on its tenant.
+type scope struct {
+	adminRole   string
+	auth        Auth
+	permissions *prefixTree[[]string]
+}
+
+// Middleware handles JWT authentication in server requests.
+func (m *Middleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := m.Authorize(w, r)
+		if err != nil {
+			// Requests whose role isn't allowed get the same response as requests without valid credentials.
+			if errors.Is(err, ErrForbidden) {
+				err = ErrUnauthorized
+			}
+
+			m.errorRenderer(w, r, err)
+
+			return
+		}
+
+		// Skipped requests keep their context, so they aren't copied.
+		if ctx != r.Context() {
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authorize authenticates and authorizes a request as Middleware does, without serving it, so other transports,
+// such as gRPC, share its policy. It returns the request context with the token claims.
+//
+// Requests to skipped endpoints keep their context, and anonymous requests to optional endpoints are flagged in it.
+// Renewed tokens are written to w, and tokens aren't renewed when w is nil.
+// It fails with ErrForbidden when the role isn't allowed, with a *StepUpError when a step-up rule isn't met,
+// and with an authentication error, such as ErrUnauthorized or ErrTokenExpired, otherwise.
+func (m *Middleware) Authorize(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	policy := m.policy.Load()
+
+	if policy.skip.matches(r.URL.Path) {
+		// Skip JWT verification for endpoint requests
+		return r.Context(), nil
+	}
+
+	s, claims, hasCredentials, err := m.authenticate(r, policy)
+	if err != nil {
+		if !m.anonymous(r, policy, hasCredentials) {
+			return nil, err
+		}
+
+		return context.WithValue(r.Context(), anonymousKey{}, true), nil
+	}
+
+	allowed, rule, reason := false, "", ReasonMissingRole
+
+	userRole, ok := (*claims)["role"].(string)
+	if ok {
+		allowed, rule, reason = s.checkRolePermissions(r, userRole)
+	}
+
+	var stepUpErr *StepUpError
+
+	if allowed {
+		if stepUpErr = m.checkStepUp(r, *claims); stepUpErr != nil {
+			allowed, rule, reason = false, stepUpErr.Rule.Path, ReasonInsufficientUserAuthentication
+		}
+	}
+
+	m.audit(r, claims, userRole, allowed, rule, reason)
+
+	if stepUpErr != nil {
+		return nil, stepUpErr
+	}
+
+	if !allowed {
+		return nil, ErrForbidden
+	}
+
+	if w != nil {
+		claims = m.renew(w, r, &s.auth, claims)
+	}
+
+	m.touch(r.Context(), claims)
+
+	ctx, err := m.enrich(r.Context(), claims)
+	if err != nil {
+		log.Println("claims enrichment failed:", err)
+		return nil, ErrUnauthorized
+	}
+
+	ctx = m.impersonated(ctx, r, claims)
+
+	// Tokens issued while serving the request, such as refreshed tokens, are signed for its tenant.
+	if s.auth.tenant != nil {
+		ctx = ContextWithTenant(ctx, s.auth.tenant.ID)
+	}
+
+	// Store the claims in the request context for use in the handler.
+	return context.WithValue(ctx, m.contextKey, claims), nil
+}
+
+// authenticate verifies the request token, or its URL signature when signed URLs are used, and returns the
+// request scope and claims. It also reports whether the request has credentials.
+func (m *Middleware) authenticate(r *http.Request, policy *compiledPolicy) (scope, *jwt.MapClaims, bool, error) {
+	if m.urlSigner != nil && r.URL.Query().Has(SignatureParam) {
+		claims, err := m.urlSigner.Verify(r)
+		if err != nil {
+			if errors.Is(err, ErrTokenExpired) {
+				return scope{}, nil, true, ErrTokenExpired
+			}
+
+			log.Println(err)
+
+			return scope{}, nil, true, ErrUnauthorized
+		}
+
+		s, err := m.signedURLScope(r, claims, policy)
+		if err != nil {
+			log.Println(err)
+			return scope{}, nil, true, ErrUnauthorized
+		}
+
+		return s, &claims, true, nil
+	}
+
+	tokenString := m.extractor(r)
+	if tokenString == "" {
+		return scope{}, nil, false, ErrUnauthorized
+	}
+
+	s, err := m.scope(r, tokenString, policy)
+	if err != nil {
+		log.Println(err)
+		return scope{}, nil, true, ErrUnauthorized
+	}
+
+	claims, err := m.parseToken(r.Context(), &s.auth, tokenString)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return scope{}, nil, true, ErrTokenExpired
+		}
+
+		log.Println(err)
+
+		return scope{}, nil, true, ErrUnauthorized
+	}
+
+	// Single-use tokens are signed with the same keys, but they aren't access tokens.
+	if _, ok := (*claims)[PurposeClaim]; ok {
+		return scope{}, nil, true, ErrUnauthorized
+	}
+
+	return s, claims, true, nil
+}
+
+// GetClaims allows to extract claims from context.
+//
+// It fails with ErrAnonymous for anonymous requests to optional endpoints.
+// When a session store is used, the claims session must still be active, unless the request has a signed URL.
+// When a claims enricher is used, the claims hold the user data it loaded.
+func (m *Middleware) GetClaims(ctx context.Context) (Claims, error) {
+	claims, err := ClaimsFromContext(ctx, m.contextKey)
+	if err != nil {
+		if errors.Is(err, ErrClaimsNotFound) && IsAnonymous(ctx) {
+			return Claims{}, ErrAnonymous
+		}
+
+		return Claims{}, err
+	}
+
+	if m.sessions != nil && !claims.SignedURL {
+		ok, err := m.sessions.Exists(ctx, claims.ID)
+		if err != nil {
+			return Claims{}, fmt.Errorf("session store error: %v", err)
+		}
+
+		if !ok {
+			return Claims{}, ErrSessionNotFound
+		}
+	}
+
+	if enrichment, ok := EnrichmentFromContext(ctx); ok {
+		claims.Enrichment = enrichment
+	}
+
+	return claims, nil
+}
+
+// Logout removes claims from the context, effectively logging the user out.
+//
+// When a session store is used, the claims session is also removed, and the token is denied when the store
+// keeps a denylist, so it is rejected by the middleware.
+// When a claims enricher is used, the user data it cached is discarded.
+// When a token cache is used, the token is removed from it.
+func (m *Middleware) Logout(ctx context.Context) context.Context {
+	if m.tokenCache != nil {
+		if claims, err := ClaimsFromContext(ctx, m.contextKey); err == nil {
+			m.tokenCache.invalidate(claims.ID)
+		}
+	}
+
+	if m.enricher != nil {
+		claims, err := ClaimsFromContext(ctx, m.contextKey)
+		if err == nil {
+			if err = m.enricher.Invalidate(ctx, claims); err != nil {
+				log.Println("claims enrichment invalidation failed:", err)
+			}
+		}
+	}
+
+	if m.sessions != nil {
+		claims, err := ClaimsFromContext(ctx, m.contextKey)
+		if err == nil && claims.ExpiresAt > 0 && time.Until(time.Unix(claims.ExpiresAt, 0)) > 0 {
+			m.revoke(ctx, claims.ID, time.Until(time.Unix(claims.ExpiresAt, 0)))
+		}
+	}
+
+	return context.WithValue(ctx, m.contextKey, nil)
+}
+
+// Login issues a signed token for the given user data.
+//
+// When multi-tenancy is used, the token is signed for the tenant stored in context, and it fails with
+// ErrTenantNotFound when there's none.
+// The user authentication stored in context sets the "acr", "amr" and "auth_time" claims.
+// When the token is refreshed, it keeps the session start stored in context, and it fails with ErrTokenExpired
+// past the session maximum lifetime.
+// When a session store is used, the token session is saved for the token duration, with the session metadata
+// stored in context.
+func (m *Middleware) Login(ctx context.Context, subject, issuer, audience, role string) (string, error) {
+	s, err := m.contextScope(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	claims := NewMapClaims(subject, issuer, audience, role, s.auth.TokenDuration)
+	ttl := s.auth.TokenDuration
+
+	if originalIssuedAt, ok := ctx.Value(originalIssuedAtKey{}).(time.Time); ok {
+		claims[originalIssuedAtClaim] = originalIssuedAt.Unix()
+
+		if m.renewal.MaxLifetime > 0 {
+			limit := originalIssuedAt.Add(m.renewal.MaxLifetime)
+			if !limit.After(time.Now()) {
+				return "", ErrTokenExpired
+			}
+
+			if ttl = time.Until(limit); ttl < s.auth.TokenDuration {
+				claims["exp"] = limit.Unix()
+			} else {
+				ttl = s.auth.TokenDuration
+			}
+		}
+	}
+
+	if userAuth, ok := UserAuthenticationFromContext(ctx); ok {
+		userAuth.SetClaims(claims)
+	}
+
+	tokenString, err := s.auth.ClaimsToken(claims)
+	if err != nil {
+		return "", fmt.Errorf("login failed: %v", err)
+	}
+
+	err = m.saveSession(ctx, claims["id"].(string), subject, tokenString, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// saveSession saves the session of an issued token, when a session store is used,
+// with the session metadata stored in context.
+func (m *Middleware) saveSession(ctx context.Context, id, subject, tokenString string, ttl time.Duration) error {
+	if m.sessions == nil {
+		return nil
+	}
+
+	metadata, _ := SessionMetadataFromContext(ctx)
+	metadata.Subject = subject
+	metadata.CreatedAt = time.Now()
+	metadata.LastSeenAt = metadata.CreatedAt
+
+	ctx = ContextWithSessionMetadata(ctx, metadata)
+
+	if err := m.sessions.Save(ctx, id, tokenString, ttl); err != nil {
+		return fmt.Errorf("session save failed: %v", err)
+	}
+
+	return nil
+}
+
+// scope returns the settings used to authenticate a request, from its tenant when multi-tenancy is used.
+func (m *Middleware) scope(r *http.Request, tokenString string, policy *compiledPolicy) (scope, error) {
+	if m.tenants == nil {
+		return scope{
+			adminRole:   policy.AdminRole,
+			auth:        m.auth,
+			permissions: policy.permissions,
+		}, nil
+	}
+
+	tenant, err := ResolveTenant(r, tokenString, m.tenantExtractor, m.tenants)
+	if err != nil {
+		return scope{}, err
+	}
+
+	return m.tenantScope(tenant), nil
+}
+
+// signedURLScope returns the settings used to authorize a signed URL request, from the URL tenant when
+// multi-tenancy is used. The request tenant, such as its host, must be the URL one.
+func (m *Middleware) signedURLScope(r *http.Request, claims jwt.MapClaims, policy *compiledPolicy) (scope, error) {
+	if m.tenants == nil {
+		return scope{
+			adminRole:   policy.AdminRole,
+			auth:        m.auth,
+			permissions: policy.permissions,
+		}, nil
+	}
+
+	tenantID, _ := claims[TenantClaim].(string)
+
+	if m.tenantExtractor != nil {
+		if requestTenant := m.tenantExtractor(r, ""); requestTenant != "" && requestTenant != tenantID {
+			return scope{}, ErrTenantMismatch
+		}
+	}
+
+	tenant, err := resolveTenant(r.Context(), tenantID, m.tenants)
+	if err != nil {
+		return scope{}, err
+	}
+
+	return m.tenantScope(tenant), nil
+}
+
+// tenantScope returns the settings used to authenticate the requests of a tenant.
+func (m *Middleware) tenantScope(tenant Tenant) scope {
+	return scope{
+		adminRole:   tenant.AdminRole,
+		auth:        m.auth.ForTenant(tenant),
+		permissions: tenant.compile().permissions,
+	}
+}
+
+// renew re-issues the token when it is within the renewal window and sends it back to the client.
+// A token is renewed once: concurrent requests sent with it within the grace period get the same renewed token.
+// When a session store is used, the renewed token gets a new session, while the current session stays active for the
+// renewal grace period, so concurrent requests sent with it still succeed. The current token is then revoked.
+// It returns the claims to be stored in context.
+func (m *Middleware) renew(w http.ResponseWriter, r *http.Request, auth *Auth, claims *jwt.MapClaims) *jwt.MapClaims {
+	// Impersonation tokens are short-lived, so they aren't renewed, and signed URLs have no token.
+	_, impersonation := (*claims)[ImpersonationClaim]
+	_, signedURL := (*claims)[SignedURLClaim]
+
+	if impersonation || signedURL || !m.renewal.due(*claims) {
+		return claims
+	}
+
+	id, _ := (*claims)["id"].(string)
+	next := &successor{}
+
+	// Tokens without ID can't be told apart, so they are renewed on every request.
+	if id != "" {
+		next = m.successors.get(id, m.renewal.gracePeriod())
+	}
+
+	next.once.Do(func() {
+		next.claims, next.token = m.issueSuccessor(r, auth, *claims)
+	})
+
+	if next.claims == nil {
+		m.successors.forget(id, next)
+		return claims
+	}
+
+	expirationTime, _ := next.claims.GetExpirationTime()
+	m.renewal.WriteToken(w, next.token, expirationTime.Time)
+
+	renewed := maps.Clone(next.claims)
+
+	return &renewed
+}
+
+// issueSuccessor issues the token that renews the given claims, and returns its claims, or nil when the token isn't
+// renewed. When a session store is used, only an active session can be renewed, so a revoked token doesn't get a
+// new one.
+func (m *Middleware) issueSuccessor(r *http.Request, auth *Auth, claims jwt.MapClaims) (jwt.MapClaims, string) {
+	renewed, ok := auth.RenewClaims(claims, m.renewal)
+	if !ok {
+		return nil, ""
+	}
+
+	tokenString, err := auth.ClaimsToken(renewed)
+	if err != nil {
+		log.Println("token renewal failed:", err)
+		return nil, ""
+	}
+
+	if m.sessions == nil {
+		return renewed, tokenString
+	}
+
+	ctx := r.Context()
+	id, _ := claims["id"].(string)
+
+	active, err := m.sessions.Exists(ctx, id)
+	if err != nil || !active {
+		return nil, ""
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	metadata := RequestSessionMetadata(r, "")
+	metadata.Subject = subject
+	metadata.CreatedAt = time.Now()
+	metadata.LastSeenAt = metadata.CreatedAt
+	metadata.RenewedFrom = id
+
+	expirationTime, _ := renewed.GetExpirationTime()
+
+	err = m.sessions.Save(ContextWithSessionMetadata(ctx, metadata), renewed["id"].(string), tokenString,
+		time.Until(expirationTime.Time))
+	if err != nil {
+		log.Println("session save failed:", err)
+		return nil, ""
+	}
+
+	// The current token expires within the renewal window, which bounds how long it stays valid if the process
+	// stops before the grace period ends.
+	currentExpiration, _ := claims.GetExpirationTime()
+	ctx = context.WithoutCancel(ctx)
+
+	time.AfterFunc(m.renewal.gracePeriod(), func() {
+		if ttl := time.Until(currentExpiration.Time); ttl > 0 {
+			m.revoke(ctx, id, ttl)
+		}
+	})
+
+	return renewed, tokenString
+}
+
+// revoke removes the session of a token, and denies the token for its remaining lifetime when the session store
+// keeps a denylist. The token is also removed from cache.
+func (m *Middleware) revoke(ctx context.Context, id string, ttl time.Duration) {
+	if m.tokenCache != nil {
+		m.tokenCache.invalidate(id)
+	}
+
+	if denylist, ok := m.sessions.(TokenDenylist); ok {
+		if err := denylist.Deny(ctx, id, ttl); err != nil {
+			log.Println("token deny failed:", err)
+		}
+
+		return
+	}
+
+	if _, err := m.sessions.Delete(ctx, id); err != nil {
+		log.Println("session delete failed:", err)
+	}
+}
+
+// checkDenylist fails with ErrUnauthorized when the token is in the session store denylist.
+func (m *Middleware) checkDenylist(ctx context.Context, id string) error {
+	denylist, ok := m.sessions.(TokenDenylist)
+	if !ok {
+		return nil
+	}
+
+	denied, err := denylist.Denied(ctx, id)
+	if err != nil {
+		return fmt.Errorf("session store error: %v", err)
+	}
+
+	if denied {
+		return fmt.Errorf("%w: token %s is denied", ErrUnauthorized, id)
+	}
+
+	return nil
+}
+
+// touch records the session activity, when the session store tracks it.
+func (m *Middleware) touch(ctx context.Context, claims *jwt.MapClaims) {
+	// Signed URLs have no session.
+	if _, signedURL := (*claims)[SignedURLClaim]; signedURL {
+		return
+	}
+
+	tracker, ok := m.sessions.(SessionTracker)
+	if !ok {
+		return
+	}
+
+	subject, _ := (*claims)["sub"].(string)
+	id, _ := (*claims)["id"].(string)
+
+	if err := tracker.Touch(ctx, subject, id); err != nil {
+		log.Println("session touch failed:", err)
+	}
+}
+
+// checkRolePermissions verifies if current user role is allowed to access current URL request
+// according to permission mapping previously defined.
+//
+// The longest matching endpoint decides the outcome, so specific rules override the rules of their parent paths.
+// It also returns that permission rule, and the reason of the decision.
+func (s scope) checkRolePermissions(r *http.Request, userRole string) (bool, string, string) {
+	if s.adminRole != "" && userRole == s.adminRole {
+		return true, "", ReasonAdminRole
+	}
+
+	rule, roles, ok := s.permissions.longest(r.URL.Path)
+
+	switch {
+	case !ok:
+		return true, "", ReasonNoMatchingRule
+	case slices.Contains(roles, userRole):
+		return true, rule, ReasonRoleAllowed
+	default:
+		return false, rule, ReasonRoleNotAllowed
+	}
+}
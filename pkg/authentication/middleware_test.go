@@ -0,0 +1,434 @@
+package authentication_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/audit"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+// serve runs a request with the given token through the middleware, and returns the claims seen by the handler.
+func serve(
+	t *testing.T,
+	middleware *authentication.Middleware,
+	req *http.Request,
+	tokenString string,
+) (*httptest.ResponseRecorder, authentication.Claims, error) {
+	t.Helper()
+
+	var (
+		claims    authentication.Claims
+		claimsErr error
+	)
+
+	if tokenString != "" {
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+	}
+
+	rr := httptest.NewRecorder()
+
+	middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, claimsErr = middleware.GetClaims(r.Context())
+
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+
+	return rr, claims, claimsErr
+}
+
+func TestMiddleware_SessionStore(t *testing.T) {
+	store := authtest.NewSessionStore()
+	middleware := authentication.NewMiddleware(
+		authentication.Default("secret", 3600),
+		authentication.WithSessionStore(store),
+	)
+
+	tokenString, err := middleware.Login(context.Background(), "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+	assert.Len(t, store.Sessions(), 1)
+
+	rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	// Logout revokes the session, so its token claims are no longer valid.
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, err = middleware.GetClaims(middleware.Logout(r.Context()))
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.ErrorIs(t, err, authentication.ErrClaimsNotFound)
+	assert.Empty(t, store.Sessions())
+
+	// The token is also denied until it expires, so the middleware rejects it.
+	calls := store.Calls()
+	assert.Equal(t, authtest.OperationDeny, calls[len(calls)-1].Operation)
+	assert.InDelta(t, time.Hour, calls[len(calls)-1].TTL, float64(time.Minute))
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMiddleware_Renewal(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tests := []struct {
+		name            string
+		activeSession   bool
+		expectedRenewed bool
+	}{
+		{
+			name:            "Active session",
+			activeSession:   true,
+			expectedRenewed: true,
+		},
+		{
+			name:            "Revoked session",
+			activeSession:   false,
+			expectedRenewed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := authtest.NewSessionStore()
+			middleware := authentication.NewMiddleware(
+				auth,
+				authentication.WithSessionStore(store),
+				authentication.WithRenewal(authentication.Renewal{Window: 5 * time.Minute, GracePeriod: 50 * time.Millisecond}),
+			)
+
+			claims := authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Minute)
+
+			tokenString, err := auth.ClaimsToken(claims)
+			require.NoError(t, err)
+
+			if tt.activeSession {
+				require.NoError(t, store.Save(context.Background(), claims["id"].(string), tokenString, time.Minute))
+			}
+
+			rr, renewedClaims, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			renewedToken := rr.Header().Get(authentication.DefaultRenewalHeader)
+			if !tt.expectedRenewed {
+				assert.Empty(t, renewedToken)
+				return
+			}
+
+			assert.NotEmpty(t, renewedToken)
+			assert.NotEqual(t, claims["id"], renewedClaims.ID)
+			assert.Contains(t, store.Sessions(), renewedClaims.ID)
+
+			// Concurrent requests sent with the current token get the same renewed token, and its session stays
+			// active for the grace period.
+			rr, _, err = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.NoError(t, err)
+			assert.Equal(t, renewedToken, rr.Header().Get(authentication.DefaultRenewalHeader))
+			assert.Contains(t, store.Sessions(), claims["id"])
+
+			// The current token is then denied until it expires.
+			assert.Eventually(t, func() bool {
+				rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+				return rr.Code == http.StatusUnauthorized
+			}, time.Second, 10*time.Millisecond)
+
+			assert.NotContains(t, store.Sessions(), claims["id"])
+		})
+	}
+}
+
+func TestMiddleware_Tenants(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tenants := &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", SigningKey: []byte("secret-a"), PermissionsMap: map[string][]string{"/admin": {"admin"}}},
+			"tenant-b": {ID: "tenant-b", SigningKey: []byte("secret-b"), AdminRole: "owner"},
+		},
+	}
+
+	tenantAuth := auth.ForTenant(tenants.tenants["tenant-a"])
+
+	tokenString, err := tenantAuth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Hour))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		tenant         string
+		requestPath    string
+		expectedStatus int
+	}{
+		{
+			name:           "Token tenant",
+			tenant:         "tenant-a",
+			requestPath:    "/user",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Token tenant permissions",
+			tenant:         "tenant-a",
+			requestPath:    "/admin",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Different tenant",
+			tenant:         "tenant-b",
+			requestPath:    "/user",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown tenant",
+			tenant:         "tenant-z",
+			requestPath:    "/user",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	middleware := authentication.NewMiddleware(
+		auth,
+		authentication.WithAdminRole("admin"),
+		authentication.WithTenants(tenants, authentication.TenantFromHeader("X-Tenant")),
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.requestPath, nil)
+			req.Header.Set("X-Tenant", tt.tenant)
+
+			rr, claims, _ := serve(t, middleware, req, tokenString)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "tenant-a", claims.Tenant)
+			}
+		})
+	}
+}
+
+func TestMiddleware_TenantPermissionsChange(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tenants := &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", PermissionsMap: map[string][]string{"/reports": {"manager"}}},
+		},
+	}
+
+	tenantAuth := auth.ForTenant(tenants.tenants["tenant-a"])
+
+	tokenString, err := tenantAuth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "audience", "user", time.Hour))
+	require.NoError(t, err)
+
+	middleware := authentication.NewMiddleware(auth, authentication.WithTenants(tenants, nil))
+
+	rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/reports", nil), tokenString)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// A tenant resolved with new permissions isn't authorized with the previously compiled ones.
+	tenants.tenants["tenant-a"] = authentication.Tenant{
+		ID:             "tenant-a",
+		PermissionsMap: map[string][]string{"/reports": {"manager", "user"}},
+	}
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/reports", nil), tokenString)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Cached tenants keep their compiled permissions until they are resolved again.
+	cache := authentication.NewTenantCache(tenants, time.Hour)
+	middleware = authentication.NewMiddleware(auth, authentication.WithTenants(cache, nil))
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/reports", nil), tokenString)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	tenants.tenants["tenant-a"].PermissionsMap["/reports"] = []string{"manager"}
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/reports", nil), tokenString)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	cache.Invalidate("tenant-a")
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/reports", nil), tokenString)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestDefaultErrorRenderer(t *testing.T) {
+	rr := httptest.NewRecorder()
+	authentication.DefaultErrorRenderer(rr, httptest.NewRequest(http.MethodGet, "/user", nil), authentication.ErrForbidden)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"message":"`+authentication.ErrForbidden.Error()+`"}`, rr.Body.String())
+
+	// CORS headers are left to the CORS middleware, so error responses don't allow every origin.
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestMiddleware_Options(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tokenString, err := auth.ClaimsSignedToken("user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	expiredToken, err := auth.ClaimsToken(authentication.NewMapClaims("user-1", "issuer", "audience", "user", -time.Minute))
+	require.NoError(t, err)
+
+	middleware := authentication.NewMiddleware(
+		auth,
+		authentication.WithExtractor(authentication.TokenFromCookie("session")),
+		authentication.WithErrorRenderer(func(w http.ResponseWriter, _ *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}),
+	)
+
+	t.Run("Token from cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: tokenString})
+
+		rr, claims, err := serve(t, middleware, req, "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: expiredToken})
+
+		rr, _, _ := serve(t, middleware, req, "")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, "token has expired\n", rr.Body.String())
+	})
+
+	t.Run("Ignored Authorization header", func(t *testing.T) {
+		rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, "unauthorized\n", rr.Body.String())
+	})
+}
+
+func TestMiddleware_Audience(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	middleware := authentication.NewMiddleware(auth, authentication.WithAudience(authtest.Audience))
+
+	rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil),
+		authtest.ValidToken(t, auth, "user-1", "user"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Equal(t, authtest.Audience, claims.Audience)
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil),
+		authtest.WrongAudienceToken(t, auth, "user-1", "user"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMiddleware_AuditSink(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	sink := audit.NewMemory()
+
+	middleware := authentication.NewMiddleware(
+		auth,
+		authentication.WithAdminRole("admin"),
+		authentication.WithPermissions(map[string][]string{
+			"/admin":       {"admin"},
+			"/admin/users": {"manager"},
+		}),
+		authentication.WithAuditSink(sink),
+	)
+
+	tests := []struct {
+		name             string
+		role             string
+		requestPath      string
+		expectedDecision authentication.Decision
+	}{
+		{
+			name:        "Admin role",
+			role:        "admin",
+			requestPath: "/admin",
+			expectedDecision: authentication.Decision{
+				Roles:   []string{"admin"},
+				Outcome: authentication.OutcomeAllow,
+				Reason:  authentication.ReasonAdminRole,
+			},
+		},
+		{
+			name:        "Allowed role",
+			role:        "manager",
+			requestPath: "/admin/users/1",
+			expectedDecision: authentication.Decision{
+				Roles:   []string{"manager"},
+				Rule:    "/admin/users",
+				Outcome: authentication.OutcomeAllow,
+				Reason:  authentication.ReasonRoleAllowed,
+			},
+		},
+		{
+			name:        "Denied role",
+			role:        "user",
+			requestPath: "/admin/users/1",
+			expectedDecision: authentication.Decision{
+				Roles:   []string{"user"},
+				Rule:    "/admin/users",
+				Outcome: authentication.OutcomeDeny,
+				Reason:  authentication.ReasonRoleNotAllowed,
+			},
+		},
+		{
+			name:        "No matching rule",
+			role:        "user",
+			requestPath: "/user",
+			expectedDecision: authentication.Decision{
+				Roles:   []string{"user"},
+				Outcome: authentication.OutcomeAllow,
+				Reason:  authentication.ReasonNoMatchingRule,
+			},
+		},
+		{
+			name:        "Missing role",
+			requestPath: "/user",
+			expectedDecision: authentication.Decision{
+				Outcome: authentication.OutcomeDeny,
+				Reason:  authentication.ReasonMissingRole,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink.Reset()
+
+			claims := authentication.NewMapClaims("user-1", "issuer", "audience", tt.role, time.Hour)
+			if tt.role == "" {
+				delete(claims, "role")
+			}
+
+			tokenString, err := auth.ClaimsToken(claims)
+			require.NoError(t, err)
+
+			serve(t, middleware, httptest.NewRequest(http.MethodGet, tt.requestPath, nil), tokenString)
+
+			decisions := sink.Decisions()
+			require.Len(t, decisions, 1)
+			assert.WithinDuration(t, time.Now(), decisions[0].Time, time.Second)
+
+			tt.expectedDecision.Time = decisions[0].Time
+			tt.expectedDecision.Subject = "user-1"
+			tt.expectedDecision.Method = http.MethodGet
+			tt.expectedDecision.Route = tt.requestPath
+			assert.Equal(t, tt.expectedDecision, decisions[0])
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+)
+
+// InvalidTokenAction is what optional authentication does with requests with invalid tokens.
+type InvalidTokenAction int
+
+const (
+	// RejectInvalidToken rejects requests with invalid tokens, as protected endpoints do.
+	RejectInvalidToken InvalidTokenAction = iota
+	// IgnoreInvalidToken continues requests with invalid tokens as anonymous requests.
+	IgnoreInvalidToken
+)
+
+type anonymousKey struct{}
+
+// IsAnonymous reports whether the request was continued without claims by optional authentication.
+func IsAnonymous(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousKey{}).(bool)
+
+	return anonymous
+}
+
+// isOptional reports whether tokens are optional on the request endpoint.
+func isOptional(r *http.Request, policy *compiledPolicy) bool {
+	return policy.optional.matches(r.URL.Path)
+}
+
+// anonymous reports whether a request without a valid token continues as anonymous, which optional endpoints do
+// when it has no token or invalid tokens are ignored.
+func (m *Middleware) anonymous(r *http.Request, policy *compiledPolicy, hasToken bool) bool {
+	return isOptional(r, policy) && (!hasToken || m.invalidTokenAction == IgnoreInvalidToken)
+}
@@ -0,0 +1,89 @@
+package authentication_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestMiddleware_Optional(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	validToken, err := auth.ClaimsSignedToken("user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		action         authentication.InvalidTokenAction
+		path           string
+		token          string
+		expectedStatus int
+		expectedErr    error
+		expectedClaims authentication.Claims
+	}{
+		{
+			name:           "Optional endpoint without token",
+			path:           "/public/articles",
+			expectedStatus: http.StatusOK,
+			expectedErr:    authentication.ErrAnonymous,
+		},
+		{
+			name:           "Optional endpoint with valid token",
+			path:           "/public/articles",
+			token:          validToken,
+			expectedStatus: http.StatusOK,
+			expectedClaims: authentication.Claims{Subject: "user-1", Role: "user"},
+		},
+		{
+			name:           "Optional endpoint with rejected invalid token",
+			action:         authentication.RejectInvalidToken,
+			path:           "/public/articles",
+			token:          "invalid-token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Optional endpoint with ignored invalid token",
+			action:         authentication.IgnoreInvalidToken,
+			path:           "/public/articles",
+			token:          "invalid-token",
+			expectedStatus: http.StatusOK,
+			expectedErr:    authentication.ErrAnonymous,
+		},
+		{
+			name:           "Protected endpoint without token",
+			action:         authentication.IgnoreInvalidToken,
+			path:           "/orders",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := authentication.NewMiddleware(auth,
+				authentication.WithOptional("/public"),
+				authentication.WithInvalidTokenAction(tt.action),
+			)
+
+			rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, tt.path, nil), tt.token)
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedClaims.Subject, claims.Subject)
+			assert.Equal(t, tt.expectedClaims.Role, claims.Role)
+		})
+	}
+}
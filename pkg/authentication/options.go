@@ -0,0 +1,176 @@
+package authentication
+
+// Option configures a Middleware.
+type Option func(m *Middleware)
+
+// WithAdminRole sets the maximum permission role, that allows everything by default.
+func WithAdminRole(role string) Option {
+	return func(m *Middleware) {
+		m.updatePolicy(func(policy *Policy) {
+			policy.AdminRole = role
+		})
+	}
+}
+
+// WithSkip sets the endpoints that are ignored for JWT verification, by path prefix.
+func WithSkip(paths ...string) Option {
+	return func(m *Middleware) {
+		m.updatePolicy(func(policy *Policy) {
+			policy.SkipList = append(policy.SkipList, paths...)
+		})
+	}
+}
+
+// WithOptional sets the endpoints, by path prefix, where tokens are optional.
+// Requests with a valid token get their claims, while requests without a token continue as anonymous.
+func WithOptional(paths ...string) Option {
+	return func(m *Middleware) {
+		m.updatePolicy(func(policy *Policy) {
+			policy.OptionalList = append(policy.OptionalList, paths...)
+		})
+	}
+}
+
+// WithInvalidTokenAction sets what optional endpoints do with invalid tokens. It defaults to RejectInvalidToken.
+func WithInvalidTokenAction(action InvalidTokenAction) Option {
+	return func(m *Middleware) {
+		m.invalidTokenAction = action
+	}
+}
+
+// WithPermissions sets the endpoints, by path prefix, associated to the allowed permission roles.
+// The longest endpoint that prefixes a request path sets its allowed roles.
+func WithPermissions(permissionsMap map[string][]string) Option {
+	return func(m *Middleware) {
+		m.updatePolicy(func(policy *Policy) {
+			policy.PermissionsMap = permissionsMap
+		})
+	}
+}
+
+// WithAudience rejects tokens that weren't issued for the given audience, as the Auth Audience does.
+func WithAudience(audience string) Option {
+	return func(m *Middleware) {
+		m.auth.Audience = audience
+	}
+}
+
+// WithSessionStore keeps the server side sessions of issued tokens in the given store.
+// GetClaims fails for tokens without an active session.
+func WithSessionStore(store SessionStore) Option {
+	return func(m *Middleware) {
+		m.sessions = store
+	}
+}
+
+// WithExtractor sets how tokens are extracted from requests. It defaults to the Authorization Bearer token.
+func WithExtractor(extractor TokenExtractor) Option {
+	return func(m *Middleware) {
+		m.extractor = extractor
+	}
+}
+
+// WithErrorRenderer sets how authentication errors are written. It defaults to DefaultErrorRenderer.
+func WithErrorRenderer(renderer ErrorRenderer) Option {
+	return func(m *Middleware) {
+		m.errorRenderer = renderer
+	}
+}
+
+// WithTokenCache caches the claims of up to size verified tokens, until they expire, so the signature of tokens
+// sent again isn't verified on every request. Tokens are cached with the tenant and verification key they were
+// verified with, so they are verified again after a key rotation. The least recently used tokens are evicted first.
+// Logout removes the token from cache. When the session store keeps a denylist, cached tokens are checked against
+// it at most every few seconds, so tokens revoked by other instances are rejected shortly after.
+func WithTokenCache(size int) Option {
+	return func(m *Middleware) {
+		if size > 0 {
+			m.tokenCache = newTokenCache(size)
+		}
+	}
+}
+
+// WithSignedURLs authenticates requests with a signed URL, verified by the given signer, as well as tokens.
+// Signed URL requests get the signed subject and role as claims, so permission rules still apply.
+func WithSignedURLs(signer *URLSigner) Option {
+	return func(m *Middleware) {
+		m.urlSigner = signer
+	}
+}
+
+// WithRenewal enables sliding session expiration.
+func WithRenewal(renewal Renewal) Option {
+	return func(m *Middleware) {
+		m.renewal = renewal
+	}
+}
+
+// WithTenants resolves the keys, issuer and permissions of each request tenant.
+// The tenant ID is extracted with the given extractor, which defaults to the token tenant claim when nil.
+func WithTenants(resolver TenantResolver, extractor TenantExtractor) Option {
+	return func(m *Middleware) {
+		m.tenants = resolver
+		m.tenantExtractor = extractor
+	}
+}
+
+// WithPolicy sets the authorization rules, replacing the admin role, skip list and permissions.
+// The policy can be changed later with SetPolicy or WatchPolicy.
+func WithPolicy(policy Policy) Option {
+	return func(m *Middleware) {
+		m.updatePolicy(func(current *Policy) {
+			*current = policy
+		})
+	}
+}
+
+// WithContextKey sets the context key of the request claims. It defaults to the Auth claims key.
+func WithContextKey(key any) Option {
+	return func(m *Middleware) {
+		m.contextKey = key
+	}
+}
+
+// WithAuditSink records every authorization decision in the given sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(m *Middleware) {
+		m.auditSink = sink
+	}
+}
+
+// WithClaimsEnricher adds the user data loaded by the given enricher to the claims of valid tokens.
+// Requests fail when the user data can't be loaded.
+func WithClaimsEnricher(enricher ClaimsEnricher) Option {
+	return func(m *Middleware) {
+		m.enricher = enricher
+	}
+}
+
+// WithImpersonation enables Impersonate, with the given policy.
+// Impersonation tokens and the requests made with them are recorded in the given log, which defaults to the
+// standard logger when nil.
+func WithImpersonation(policy ImpersonationPolicy, impersonationLog ImpersonationLog) Option {
+	return func(m *Middleware) {
+		m.impersonation = policy
+
+		if impersonationLog != nil {
+			m.impersonationLog = impersonationLog
+		}
+	}
+}
+
+// WithTokenExchange enables Exchange, for the clients of the given policy.
+func WithTokenExchange(policy ExchangePolicy) Option {
+	return func(m *Middleware) {
+		m.exchange = policy
+	}
+}
+
+// WithStepUp requires a stronger or recent user authentication on the endpoints of the given rules.
+// Requests that don't meet a matching rule fail with a StepUpError.
+func WithStepUp(rules ...StepUpRule) Option {
+	return func(m *Middleware) {
+		m.stepUpRules = append(m.stepUpRules, rules...)
+		m.stepUpTree = newStepUpTree(m.stepUpRules)
+	}
+}
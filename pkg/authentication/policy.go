@@ -0,0 +1,222 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy holds the authorization rules of a Middleware.
+//
+// AdminRole is the maximum permission role, that allows everything by default.
+// PermissionsMap is the list of endpoints, by path prefix, associated to the allowed permission roles.
+// The longest endpoint that prefixes a request path sets its allowed roles.
+// SkipList is the list of endpoints, by path prefix, that are ignored for JWT verification.
+// OptionalList is the list of endpoints, by path prefix, where requests without a token continue as anonymous.
+type Policy struct {
+	AdminRole      string              `json:"admin_role" yaml:"admin_role"`
+	PermissionsMap map[string][]string `json:"permissions" yaml:"permissions"`
+	SkipList       []string            `json:"skip" yaml:"skip"`
+	OptionalList   []string            `json:"optional" yaml:"optional"`
+}
+
+// Validate checks that every endpoint is a path and every permission has allowed roles.
+func (p Policy) Validate() error {
+	for path, roles := range p.PermissionsMap {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%w: permission endpoint %q must start with /", ErrInvalidPolicy, path)
+		}
+
+		if len(roles) == 0 {
+			return fmt.Errorf("%w: permission endpoint %q has no roles", ErrInvalidPolicy, path)
+		}
+
+		for _, role := range roles {
+			if role == "" {
+				return fmt.Errorf("%w: permission endpoint %q has an empty role", ErrInvalidPolicy, path)
+			}
+		}
+	}
+
+	for _, path := range p.SkipList {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%w: skip endpoint %q must start with /", ErrInvalidPolicy, path)
+		}
+	}
+
+	for _, path := range p.OptionalList {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%w: optional endpoint %q must start with /", ErrInvalidPolicy, path)
+		}
+	}
+
+	return nil
+}
+
+// ParsePolicy decodes a JSON or YAML policy, according to the given file extension, and validates it.
+func ParsePolicy(data []byte, extension string) (Policy, error) {
+	var (
+		policy Policy
+		err    error
+	)
+
+	switch strings.ToLower(extension) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&policy)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&policy)
+	default:
+		return Policy{}, fmt.Errorf("%w: unsupported policy format %q", ErrInvalidPolicy, extension)
+	}
+
+	if err != nil {
+		return Policy{}, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+
+	return policy, policy.Validate()
+}
+
+// LoadPolicy reads and validates a JSON or YAML policy file.
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // The policy path is set by the application.
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return ParsePolicy(data, filepath.Ext(path))
+}
+
+// clone returns a deep copy of the policy, so it can't be changed by its previous owner.
+func (p Policy) clone() *Policy {
+	permissionsMap := make(map[string][]string, len(p.PermissionsMap))
+	for path, roles := range p.PermissionsMap {
+		permissionsMap[path] = slices.Clone(roles)
+	}
+
+	return &Policy{
+		AdminRole:      p.AdminRole,
+		PermissionsMap: permissionsMap,
+		SkipList:       slices.Clone(p.SkipList),
+		OptionalList:   slices.Clone(p.OptionalList),
+	}
+}
+
+// compiledPolicy is a policy with its endpoints compiled into prefix trees, so requests are matched to the
+// longest endpoint prefix without allocations.
+type compiledPolicy struct {
+	Policy
+
+	permissions *prefixTree[[]string]
+	skip        *prefixTree[struct{}]
+	optional    *prefixTree[struct{}]
+}
+
+// compile returns a compiled copy of the policy.
+func (p Policy) compile() *compiledPolicy {
+	policy := p.clone()
+
+	return &compiledPolicy{
+		Policy:      *policy,
+		permissions: newPrefixTree(policy.PermissionsMap),
+		skip:        newPrefixSet(policy.SkipList),
+		optional:    newPrefixSet(policy.OptionalList),
+	}
+}
+
+// Policy returns a copy of the current authorization rules.
+func (m *Middleware) Policy() Policy {
+	return *m.policy.Load().clone()
+}
+
+// SetPolicy validates the given authorization rules and atomically replaces the current ones,
+// so in-flight requests keep using the rules they started with.
+func (m *Middleware) SetPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	m.policy.Store(policy.compile())
+
+	return nil
+}
+
+// updatePolicy applies a change to a copy of the current policy, and stores it compiled, without validation.
+func (m *Middleware) updatePolicy(update func(policy *Policy)) {
+	policy := m.policy.Load().clone()
+	update(policy)
+	m.policy.Store(policy.compile())
+}
+
+// WatchPolicy loads a policy file, and then polls it for changes at the given interval until the context is done.
+//
+// Changed policies are validated before replacing the current one. A failed reload is logged, and the last
+// valid policy is kept. The interval must be positive.
+func (m *Middleware) WatchPolicy(ctx context.Context, path string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid policy watch interval: %v", interval)
+	}
+
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		return err
+	}
+
+	if err = m.SetPolicy(policy); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modTime, size := info.ModTime(), info.Size()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					log.Println("policy reload failed:", err)
+					continue
+				}
+
+				if info.ModTime().Equal(modTime) && info.Size() == size {
+					continue
+				}
+
+				modTime, size = info.ModTime(), info.Size()
+
+				policy, err := LoadPolicy(path)
+				if err == nil {
+					err = m.SetPolicy(policy)
+				}
+
+				if err != nil {
+					log.Println("policy reload failed, keeping the last valid policy:", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
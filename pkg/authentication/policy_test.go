@@ -0,0 +1,149 @@
+package authentication_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestParsePolicy(t *testing.T) {
+	expectedPolicy := authentication.Policy{
+		AdminRole:      "admin",
+		PermissionsMap: map[string][]string{"/reports": {"manager", "user"}},
+		SkipList:       []string{"/health"},
+	}
+
+	tests := []struct {
+		name           string
+		data           string
+		extension      string
+		expectedPolicy authentication.Policy
+		expectedErr    bool
+	}{
+		{
+			name:           "JSON policy",
+			data:           `{"admin_role":"admin","permissions":{"/reports":["manager","user"]},"skip":["/health"]}`,
+			extension:      ".json",
+			expectedPolicy: expectedPolicy,
+		},
+		{
+			name:           "YAML policy",
+			data:           "admin_role: admin\npermissions:\n  /reports: [manager, user]\nskip:\n  - /health\n",
+			extension:      ".yaml",
+			expectedPolicy: expectedPolicy,
+		},
+		{
+			name:        "Unknown field",
+			data:        `{"admin":"admin"}`,
+			extension:   ".json",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid endpoint",
+			data:        `{"permissions":{"reports":["user"]}}`,
+			extension:   ".json",
+			expectedErr: true,
+		},
+		{
+			name:        "Endpoint without roles",
+			data:        "permissions:\n  /reports: []\n",
+			extension:   ".yml",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid skip endpoint",
+			data:        `{"skip":["health"]}`,
+			extension:   ".json",
+			expectedErr: true,
+		},
+		{
+			name:        "Unsupported format",
+			data:        `admin_role = "admin"`,
+			extension:   ".toml",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := authentication.ParsePolicy([]byte(tt.data), tt.extension)
+			if tt.expectedErr {
+				assert.ErrorIs(t, err, authentication.ErrInvalidPolicy)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPolicy, policy)
+		})
+	}
+}
+
+func TestMiddleware_WatchPolicy(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tokenString, err := auth.ClaimsSignedToken("user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"permissions":{"/reports":["user"]}}`), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	middleware := authentication.NewMiddleware(auth)
+	assert.Error(t, middleware.WatchPolicy(ctx, path, 0))
+	require.NoError(t, middleware.WatchPolicy(ctx, path, 10*time.Millisecond))
+
+	status := func() int {
+		rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/reports", nil), tokenString)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status())
+
+	// A changed policy replaces the current one.
+	require.NoError(t, os.WriteFile(path, []byte(`{"permissions":{"/reports":["manager"]}}`), 0o600))
+	assert.Eventually(t, func() bool {
+		return status() == http.StatusUnauthorized
+	}, time.Second, 10*time.Millisecond)
+
+	// An invalid policy is ignored, and the last valid one is kept.
+	require.NoError(t, os.WriteFile(path, []byte(`{"permissions":{"reports":["user"]}}`), 0o600))
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, http.StatusUnauthorized, status())
+	assert.Equal(t, map[string][]string{"/reports": {"manager"}}, middleware.Policy().PermissionsMap)
+}
+
+func TestMiddleware_SetPolicy(t *testing.T) {
+	middleware := authentication.NewMiddleware(
+		authentication.Default("secret", 3600),
+		authentication.WithAdminRole("admin"),
+		authentication.WithSkip("/health"),
+	)
+
+	err := middleware.SetPolicy(authentication.Policy{SkipList: []string{"health"}})
+	assert.ErrorIs(t, err, authentication.ErrInvalidPolicy)
+	assert.Equal(t, authentication.Policy{
+		AdminRole:      "admin",
+		PermissionsMap: map[string][]string{},
+		SkipList:       []string{"/health"},
+	}, middleware.Policy())
+
+	rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/health", nil), "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	require.NoError(t, middleware.SetPolicy(authentication.Policy{}))
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, "/health", nil), "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
@@ -0,0 +1,166 @@
+package authentication
+
+import (
+	"slices"
+	"strings"
+)
+
+// prefixTree is a radix tree of path prefixes, which finds the longest prefix of a path without allocations.
+//
+// It is built once for each policy, and it is only read while serving requests.
+type prefixTree[V any] struct {
+	root prefixNode[V]
+}
+
+type prefixNode[V any] struct {
+	label    string
+	children []*prefixNode[V]
+	leaf     bool
+	prefix   string
+	value    V
+}
+
+// newPrefixTree builds the tree of the given prefixes.
+func newPrefixTree[V any](values map[string]V) *prefixTree[V] {
+	tree := &prefixTree[V]{}
+
+	for prefix, value := range values {
+		tree.insert(prefix, value)
+	}
+
+	return tree
+}
+
+// newPrefixSet builds the tree of a list of prefixes, without values.
+func newPrefixSet(prefixes []string) *prefixTree[struct{}] {
+	tree := &prefixTree[struct{}]{}
+
+	for _, prefix := range prefixes {
+		tree.insert(prefix, struct{}{})
+	}
+
+	return tree
+}
+
+func (t *prefixTree[V]) insert(prefix string, value V) {
+	node, rest := &t.root, prefix
+
+	for rest != "" {
+		i, found := node.search(rest[0])
+		if !found {
+			leaf := &prefixNode[V]{label: rest}
+			node.children = slices.Insert(node.children, i, leaf)
+			node = leaf
+
+			break
+		}
+
+		child := node.children[i]
+		common := commonPrefixLength(child.label, rest)
+
+		// The child label is split, so the prefix ends on its own node.
+		if common < len(child.label) {
+			split := &prefixNode[V]{label: child.label[:common], children: []*prefixNode[V]{child}}
+			child.label = child.label[common:]
+			node.children[i] = split
+			child = split
+		}
+
+		node, rest = child, rest[common:]
+	}
+
+	node.leaf = true
+	node.prefix = prefix
+	node.value = value
+}
+
+// longest returns the longest prefix of the path, and its value.
+func (t *prefixTree[V]) longest(path string) (string, V, bool) {
+	var match *prefixNode[V]
+
+	node := &t.root
+
+	for {
+		if node.leaf {
+			match = node
+		}
+
+		if path == "" {
+			break
+		}
+
+		i, found := node.search(path[0])
+		if !found || !strings.HasPrefix(path, node.children[i].label) {
+			break
+		}
+
+		node = node.children[i]
+		path = path[len(node.label):]
+	}
+
+	if match == nil {
+		var value V
+		return "", value, false
+	}
+
+	return match.prefix, match.value, true
+}
+
+// each calls visit with the value of every prefix of the path, from the shortest to the longest,
+// until visit returns false.
+func (t *prefixTree[V]) each(path string, visit func(prefix string, value V) bool) {
+	node := &t.root
+
+	for {
+		if node.leaf && !visit(node.prefix, node.value) {
+			return
+		}
+
+		if path == "" {
+			return
+		}
+
+		i, found := node.search(path[0])
+		if !found || !strings.HasPrefix(path, node.children[i].label) {
+			return
+		}
+
+		node = node.children[i]
+		path = path[len(node.label):]
+	}
+}
+
+// matches reports whether a prefix of the path is in the tree.
+func (t *prefixTree[V]) matches(path string) bool {
+	_, _, ok := t.longest(path)
+
+	return ok
+}
+
+// search returns the index of the child whose label starts with the given byte, and whether it exists.
+// Children are sorted by their first byte, so a missing child is inserted at the returned index.
+func (n *prefixNode[V]) search(b byte) (int, bool) {
+	low, high := 0, len(n.children)
+
+	for low < high {
+		middle := int(uint(low+high) >> 1)
+
+		if n.children[middle].label[0] < b {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+
+	return low, low < len(n.children) && n.children[low].label[0] == b
+}
+
+func commonPrefixLength(a, b string) int {
+	i := 0
+
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,168 @@
+package authentication_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/audit"
+)
+
+func TestMiddleware_LongestMatch(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	sink := audit.NewMemory()
+
+	middleware := authentication.NewMiddleware(
+		auth,
+		authentication.WithPermissions(map[string][]string{
+			"/":                 {"user", "support"},
+			"/api":              {"service"},
+			"/api/admin":        {"support"},
+			"/api/admin/public": {"user", "support"},
+			"/apis":             {"user"},
+		}),
+		authentication.WithSkip("/health", "/api/admin/public/status"),
+		authentication.WithAuditSink(sink),
+	)
+
+	tests := []struct {
+		name           string
+		role           string
+		requestPath    string
+		expectedStatus int
+		expectedRule   string
+	}{
+		{
+			name:           "Root rule",
+			role:           "user",
+			requestPath:    "/orders",
+			expectedStatus: http.StatusOK,
+			expectedRule:   "/",
+		},
+		{
+			name:           "Specific rule overrides its parent",
+			role:           "user",
+			requestPath:    "/api/orders",
+			expectedStatus: http.StatusUnauthorized,
+			expectedRule:   "/api",
+		},
+		{
+			name:           "Nested rule",
+			role:           "service",
+			requestPath:    "/api/admin/users",
+			expectedStatus: http.StatusUnauthorized,
+			expectedRule:   "/api/admin",
+		},
+		{
+			name:           "Deepest rule",
+			role:           "user",
+			requestPath:    "/api/admin/public/docs",
+			expectedStatus: http.StatusOK,
+			expectedRule:   "/api/admin/public",
+		},
+		{
+			name:           "Sibling prefix",
+			role:           "user",
+			requestPath:    "/apis/v2",
+			expectedStatus: http.StatusOK,
+			expectedRule:   "/apis",
+		},
+		{
+			name:           "Partial rule match",
+			role:           "service",
+			requestPath:    "/api/adm",
+			expectedStatus: http.StatusOK,
+			expectedRule:   "/api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink.Reset()
+
+			tokenString, err := auth.ClaimsSignedToken("user-1", "issuer", "audience", tt.role)
+			require.NoError(t, err)
+
+			rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, tt.requestPath, nil), tokenString)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			decisions := sink.Decisions()
+			require.Len(t, decisions, 1)
+			assert.Equal(t, tt.expectedRule, decisions[0].Rule)
+		})
+	}
+
+	for _, requestPath := range []string{"/health", "/healthz", "/api/admin/public/status"} {
+		rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodGet, requestPath, nil), "")
+		assert.Equal(t, http.StatusOK, rr.Code, requestPath)
+	}
+}
+
+func TestMiddleware_SkipAllocations(t *testing.T) {
+	handler, req := newLargePolicyHandler(1000, "/skip/500/files")
+	rr := httptest.NewRecorder()
+
+	allocations := testing.AllocsPerRun(100, func() {
+		handler.ServeHTTP(rr, req)
+	})
+
+	assert.Zero(t, allocations)
+}
+
+// newLargePolicyHandler returns a middleware handler with the given number of permission and skip rules,
+// and a request to the given path.
+func newLargePolicyHandler(rules int, requestPath string) (http.Handler, *http.Request) {
+	auth := authentication.Default("secret", 3600)
+
+	permissionsMap := make(map[string][]string, rules)
+	skipList := make([]string, 0, rules)
+
+	for i := range rules {
+		permissionsMap[fmt.Sprintf("/api/v1/resources/%d", i)] = []string{"admin", fmt.Sprintf("role-%d", i)}
+		skipList = append(skipList, fmt.Sprintf("/skip/%d/", i))
+	}
+
+	handler := authentication.NewMiddleware(auth,
+		authentication.WithPermissions(permissionsMap),
+		authentication.WithSkip(skipList...),
+		authentication.WithTokenCache(1),
+	).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tokenString, _ := auth.ClaimsSignedToken("user-1", "issuer", "audience", "role-500")
+
+	req := httptest.NewRequest(http.MethodGet, requestPath, nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	return handler, req
+}
+
+func BenchmarkMiddleware_LargePolicy(b *testing.B) {
+	paths := []struct {
+		name string
+		path string
+	}{
+		{name: "Skip", path: "/skip/999/files"},
+		{name: "Permission", path: "/api/v1/resources/500/items"},
+		{name: "NoMatchingRule", path: "/api/v2/resources"},
+	}
+
+	for _, path := range paths {
+		b.Run(path.name, func(b *testing.B) {
+			handler, req := newLargePolicyHandler(1000, path.path)
+			rr := httptest.NewRecorder()
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				handler.ServeHTTP(rr, req)
+			}
+		})
+	}
+}
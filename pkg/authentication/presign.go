@@ -0,0 +1,180 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signed URL query parameters.
+const (
+	SignatureParam = "X-Signature"
+	ExpiresParam   = "X-Expires"
+	MethodParam    = "X-Method"
+	SubjectParam   = "X-Subject"
+	RoleParam      = "X-Role"
+	IPParam        = "X-IP"
+	TenantParam    = "X-Tenant"
+)
+
+// urlSigningKeyLabel is the label of the URL signing key, derived from the Auth HMAC secret.
+const urlSigningKeyLabel = "presign"
+
+// SignedURLClaim marks the claims of a request authenticated by a signed URL, which has no token nor session.
+const SignedURLClaim = "signed_url"
+
+// URLGrant is what a signed URL allows.
+//
+// Method is the allowed request method, which defaults to GET.
+// Subject and Role are the claims of the requests made with the URL, so permission rules still apply.
+// IP optionally binds the URL to a client IP.
+// Tenant binds the URL to a tenant, whose permission rules apply. It is required when multi-tenancy is used.
+// TTL is the lifetime of the URL.
+type URLGrant struct {
+	Method  string
+	Subject string
+	Role    string
+	IP      string
+	Tenant  string
+	TTL     time.Duration
+}
+
+// URLSigner signs and verifies temporary URLs, such as download and upload links, with an HMAC-SHA256 signature
+// over their path and query.
+type URLSigner struct {
+	secret []byte
+}
+
+// NewURLSigner is a URLSigner constructor.
+//
+// URLs are signed with a key derived from the HMAC secret of auth, which is its signing key, or its token secret when
+// tokens are signed with a private key, so a URL signature is never a valid token signature, nor the other way around.
+// It fails with ErrMissingSecret when auth has no HMAC secret.
+func NewURLSigner(auth Auth) (*URLSigner, error) {
+	secret, ok := auth.signingKey().([]byte)
+	if !ok {
+		secret = []byte(auth.TokenSecret)
+	}
+
+	if len(secret) == 0 {
+		return nil, ErrMissingSecret
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(urlSigningKeyLabel))
+
+	return &URLSigner{secret: mac.Sum(nil)}, nil
+}
+
+// Sign returns the URL with the grant and its signature in the query.
+// Signed URL parameters already in the query are replaced.
+func (s *URLSigner) Sign(rawURL string, grant URLGrant) (string, error) {
+	if grant.TTL <= 0 || grant.Subject == "" {
+		return "", ErrInvalidRequest
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("url parse failed: %v", err)
+	}
+
+	method := strings.ToUpper(grant.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	query := u.Query()
+	query.Del(SignatureParam)
+	query.Del(IPParam)
+	query.Del(TenantParam)
+	query.Set(ExpiresParam, strconv.FormatInt(time.Now().Add(grant.TTL).Unix(), 10))
+	query.Set(MethodParam, method)
+	query.Set(SubjectParam, grant.Subject)
+	query.Set(RoleParam, grant.Role)
+
+	if grant.IP != "" {
+		query.Set(IPParam, grant.IP)
+	}
+
+	if grant.Tenant != "" {
+		query.Set(TenantParam, grant.Tenant)
+	}
+
+	query.Set(SignatureParam, s.signature(u.EscapedPath(), query))
+
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Verify checks the signature, expiration, method and IP of a signed URL request, and returns its claims,
+// which hold the URL tenant in the tenant claim.
+//
+// It fails with ErrTokenExpired for expired URLs, and with ErrInvalidSignature otherwise.
+func (s *URLSigner) Verify(r *http.Request) (jwt.MapClaims, error) {
+	query := r.URL.Query()
+
+	signature := query.Get(SignatureParam)
+	query.Del(SignatureParam)
+
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(s.signature(r.URL.EscapedPath(), query))) {
+		return nil, ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get(ExpiresParam), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	now := time.Now()
+
+	if !now.Before(time.Unix(expires, 0)) {
+		return nil, ErrTokenExpired
+	}
+
+	if r.Method != query.Get(MethodParam) {
+		return nil, fmt.Errorf("%w: method %s isn't allowed", ErrInvalidSignature, r.Method)
+	}
+
+	if ip := query.Get(IPParam); ip != "" && ip != RequestSessionMetadata(r, "").IP {
+		return nil, fmt.Errorf("%w: IP isn't allowed", ErrInvalidSignature)
+	}
+
+	claims := jwt.MapClaims{
+		"id":           signature,
+		"sub":          query.Get(SubjectParam),
+		"role":         query.Get(RoleParam),
+		"iat":          float64(now.Unix()),
+		"exp":          float64(expires),
+		SignedURLClaim: true,
+	}
+
+	if tenant := query.Get(TenantParam); tenant != "" {
+		claims[TenantClaim] = tenant
+	}
+
+	return claims, nil
+}
+
+// signature returns the signature of a path and its query, without the signature parameter.
+// The query is canonical: its keys and the values of each key are sorted, so reordered parameters still verify.
+func (s *URLSigner) signature(path string, query url.Values) string {
+	canonical := make(url.Values, len(query))
+	for key, values := range query {
+		canonical[key] = slices.Sorted(slices.Values(values))
+	}
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(path + "\n" + canonical.Encode()))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,327 @@
+package authentication_test
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+func TestNewURLSigner(t *testing.T) {
+	_, err := authentication.NewURLSigner(authentication.Default("secret", 3600))
+	require.NoError(t, err)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	auth := authentication.New("claims", 3600, nil)
+	auth.SigningKey = privateKey
+
+	_, err = authentication.NewURLSigner(auth)
+	assert.ErrorIs(t, err, authentication.ErrMissingSecret)
+
+	auth.TokenSecret = "url-secret"
+
+	_, err = authentication.NewURLSigner(auth)
+	assert.NoError(t, err)
+}
+
+func TestURLSigner_DerivedKey(t *testing.T) {
+	signer, err := authentication.NewURLSigner(authentication.Default("secret", 3600))
+	require.NoError(t, err)
+
+	signedURL, err := signer.Sign("/files/report.pdf", authentication.URLGrant{Subject: "user-1", TTL: time.Minute})
+	require.NoError(t, err)
+
+	parsedURL, err := url.Parse(signedURL)
+	require.NoError(t, err)
+
+	// A signature made with the raw token secret isn't valid.
+	query := parsedURL.Query()
+	query.Del(authentication.SignatureParam)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(parsedURL.EscapedPath() + "\n" + query.Encode()))
+
+	query.Set(authentication.SignatureParam, base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+	parsedURL.RawQuery = query.Encode()
+
+	_, err = signer.Verify(httptest.NewRequest(http.MethodGet, parsedURL.String(), nil))
+	assert.ErrorIs(t, err, authentication.ErrInvalidSignature)
+
+	_, err = signer.Verify(httptest.NewRequest(http.MethodGet, signedURL, nil))
+	assert.NoError(t, err)
+}
+
+func TestURLSigner_Verify(t *testing.T) {
+	signer, err := authentication.NewURLSigner(authentication.Default("secret", 3600))
+	require.NoError(t, err)
+
+	sign := func(rawURL string, grant authentication.URLGrant) string {
+		t.Helper()
+
+		signedURL, err := signer.Sign(rawURL, grant)
+		require.NoError(t, err)
+
+		return signedURL
+	}
+
+	download := authentication.URLGrant{Subject: "user-1", Role: "user", TTL: time.Minute}
+	signedURL := sign("https://files.example.com/files/report.pdf?version=2&tag=b&tag=a", download)
+
+	boundURL := sign("https://files.example.com/files/1",
+		authentication.URLGrant{Subject: "user-1", IP: "10.0.0.1", TTL: time.Minute})
+
+	tenantGrant := authentication.URLGrant{Subject: "user-1", Tenant: "tenant-a", TTL: time.Minute}
+
+	parsedURL, err := url.Parse(signedURL)
+	require.NoError(t, err)
+
+	parameters := strings.Split(parsedURL.RawQuery, "&")
+	slices.Reverse(parameters)
+
+	reordered := *parsedURL
+	reordered.RawQuery = strings.Join(parameters, "&")
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		remoteAddr  string
+		expectedErr error
+	}{
+		{
+			name:   "Valid URL",
+			method: http.MethodGet,
+			target: signedURL,
+		},
+		{
+			name:   "Reordered query",
+			method: http.MethodGet,
+			target: reordered.String(),
+		},
+		{
+			name:        "Changed path",
+			method:      http.MethodGet,
+			target:      strings.Replace(signedURL, "report.pdf", "salaries.pdf", 1),
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+		{
+			name:        "Changed query",
+			method:      http.MethodGet,
+			target:      strings.Replace(signedURL, "version=2", "version=3", 1),
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+		{
+			name:        "Changed role",
+			method:      http.MethodGet,
+			target:      strings.Replace(signedURL, "X-Role=user", "X-Role=admin", 1),
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+		{
+			name:        "Changed tenant",
+			method:      http.MethodGet,
+			target:      sign("https://files.example.com/files/1", tenantGrant) + "&X-Tenant=tenant-b",
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+		{
+			name:        "Missing signature",
+			method:      http.MethodGet,
+			target:      "https://files.example.com/files/report.pdf",
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+		{
+			name:        "Method not allowed",
+			method:      http.MethodPut,
+			target:      signedURL,
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+		{
+			name:        "Expired URL",
+			method:      http.MethodGet,
+			target:      sign("https://files.example.com/files/report.pdf", authentication.URLGrant{Subject: "user-1", TTL: 1}),
+			expectedErr: authentication.ErrTokenExpired,
+		},
+		{
+			name:   "Upload URL",
+			method: http.MethodPut,
+			target: sign("https://files.example.com/uploads/1", authentication.URLGrant{
+				Method:  "put",
+				Subject: "user-1",
+				TTL:     time.Minute,
+			}),
+		},
+		{
+			name:       "Bound IP",
+			method:     http.MethodGet,
+			target:     boundURL,
+			remoteAddr: "10.0.0.1:4321",
+		},
+		{
+			name:        "Other IP",
+			method:      http.MethodGet,
+			target:      boundURL,
+			remoteAddr:  "10.0.0.2:4321",
+			expectedErr: authentication.ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+
+			claims, err := signer.Verify(req)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims["sub"])
+			assert.Equal(t, true, claims[authentication.SignedURLClaim])
+		})
+	}
+
+	_, err = signer.Sign("https://files.example.com/files/1", authentication.URLGrant{Subject: "user-1"})
+	assert.ErrorIs(t, err, authentication.ErrInvalidRequest)
+}
+
+func TestMiddleware_SignedURL(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	signer, err := authentication.NewURLSigner(auth)
+	require.NoError(t, err)
+
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithPermissions(map[string][]string{"/files": {"user"}}),
+		authentication.WithSessionStore(authtest.NewSessionStore()),
+		authentication.WithRenewal(authentication.Renewal{Window: time.Hour}),
+		authentication.WithSignedURLs(signer),
+	)
+
+	userURL, err := signer.Sign("/files/report.pdf",
+		authentication.URLGrant{Subject: "user-1", Role: "user", TTL: time.Minute})
+	require.NoError(t, err)
+
+	guestURL, err := signer.Sign("/files/report.pdf",
+		authentication.URLGrant{Subject: "guest-1", Role: "guest", TTL: time.Minute})
+	require.NoError(t, err)
+
+	rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, userURL, nil), "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "user", claims.Role)
+	assert.True(t, claims.SignedURL)
+	assert.Empty(t, rr.Header().Get(authentication.DefaultRenewalHeader))
+
+	// Permission rules apply to the signed role.
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, guestURL, nil), "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr, _, _ = serve(t, middleware, httptest.NewRequest(http.MethodGet, userURL+"x", nil), "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMiddleware_SignedURLTenants(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	signer, err := authentication.NewURLSigner(auth)
+	require.NoError(t, err)
+
+	tenants := &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", PermissionsMap: map[string][]string{"/files": {"user"}}},
+			"tenant-b": {ID: "tenant-b", PermissionsMap: map[string][]string{"/files": {"manager"}}},
+		},
+	}
+
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithTenants(tenants, authentication.TenantFromHeader("X-Tenant-ID")),
+		authentication.WithSignedURLs(signer),
+	)
+
+	sign := func(tenant string) string {
+		t.Helper()
+
+		signedURL, err := signer.Sign("/files/report.pdf",
+			authentication.URLGrant{Subject: "user-1", Role: "user", Tenant: tenant, TTL: time.Minute})
+		require.NoError(t, err)
+
+		return signedURL
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		requestTenant  string
+		expectedStatus int
+	}{
+		{
+			name:           "Tenant permissions",
+			target:         sign("tenant-a"),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Same request tenant",
+			target:         sign("tenant-a"),
+			requestTenant:  "tenant-a",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Other tenant permissions",
+			target:         sign("tenant-b"),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Other request tenant",
+			target:         sign("tenant-a"),
+			requestTenant:  "tenant-b",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing tenant",
+			target:         sign(""),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown tenant",
+			target:         sign("tenant-z"),
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.requestTenant != "" {
+				req.Header.Set("X-Tenant-ID", tt.requestTenant)
+			}
+
+			rr, claims, _ := serve(t, middleware, req, "")
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "tenant-a", claims.Tenant)
+				assert.True(t, claims.SignedURL)
+			}
+		})
+	}
+}
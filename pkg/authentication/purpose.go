@@ -0,0 +1,132 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// PurposeClaim is the claim that holds the purpose of a single-use token.
+const PurposeClaim = "purpose"
+
+// Purpose is what a single-use token can be used for.
+type Purpose string
+
+// Purposes of single-use tokens.
+const (
+	PurposeEmailVerify   Purpose = "email_verify"
+	PurposePasswordReset Purpose = "password_reset"
+	PurposeMagicLink     Purpose = "magic_link"
+)
+
+// PurposeError is returned when a single-use token can't be consumed.
+//
+// Err is ErrTokenUsed, ErrWrongPurpose, ErrTokenExpired or ErrUnauthorized, which errors.Is matches.
+type PurposeError struct {
+	Purpose Purpose
+	Err     error
+}
+
+func (e *PurposeError) Error() string {
+	return fmt.Sprintf("%s token: %v", e.Purpose, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *PurposeError) Unwrap() error {
+	return e.Err
+}
+
+// PurposeClaims holds the claims of a consumed single-use token.
+type PurposeClaims struct {
+	ID        string  `json:"id"`
+	Subject   string  `json:"sub"`
+	Purpose   Purpose `json:"purpose"`
+	ExpiresAt int64   `json:"exp"`
+}
+
+// PurposeTokens issues short-lived single-use tokens, such as email verification, password reset and magic link
+// tokens, which are signed with an Auth.
+//
+// They can't be used as access tokens, since the middlewares reject tokens with a purpose claim.
+type PurposeTokens struct {
+	auth   Auth
+	replay ReplayStore
+}
+
+// NewPurposeTokens is a PurposeTokens constructor.
+//
+// auth signs and verifies the tokens.
+// replay records the consumed tokens, so each token is only consumed once. It defaults to an in-memory store when nil.
+func NewPurposeTokens(auth Auth, replay ReplayStore) *PurposeTokens {
+	if replay == nil {
+		replay = NewMemoryReplayStore()
+	}
+
+	return &PurposeTokens{
+		auth:   auth,
+		replay: replay,
+	}
+}
+
+// Issue returns a single-use token of the subject, for the given purpose, which expires after the given duration.
+func (p *PurposeTokens) Issue(subject string, purpose Purpose, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"id":         uuid.NewString(),
+		"sub":        subject,
+		"iat":        now.Unix(),
+		"exp":        now.Add(ttl).Unix(),
+		PurposeClaim: string(purpose),
+	}
+
+	return p.auth.ClaimsToken(claims)
+}
+
+// Consume verifies a single-use token for the given purpose, and atomically records it as used.
+//
+// It fails with a PurposeError when the token is invalid, expired, issued for another purpose or already used.
+func (p *PurposeTokens) Consume(ctx context.Context, tokenString string, purpose Purpose) (PurposeClaims, error) {
+	claims, err := p.auth.ParseToken(tokenString)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return PurposeClaims{}, &PurposeError{Purpose: purpose, Err: ErrTokenExpired}
+		}
+
+		return PurposeClaims{}, &PurposeError{Purpose: purpose, Err: ErrUnauthorized}
+	}
+
+	tokenPurpose, _ := (*claims)[PurposeClaim].(string)
+	if Purpose(tokenPurpose) != purpose {
+		return PurposeClaims{}, &PurposeError{Purpose: purpose, Err: ErrWrongPurpose}
+	}
+
+	id, _ := (*claims)["id"].(string)
+	subject, _ := (*claims)["sub"].(string)
+
+	expirationTime, err := claims.GetExpirationTime()
+	if err != nil || expirationTime == nil || id == "" {
+		return PurposeClaims{}, &PurposeError{Purpose: purpose, Err: ErrUnauthorized}
+	}
+
+	// The token is recorded until it expires, after which it's rejected anyway.
+	ok, err := p.replay.Use(ctx, "purpose:"+id, time.Until(expirationTime.Time)+time.Second)
+	if err != nil {
+		return PurposeClaims{}, fmt.Errorf("replay store error: %v", err)
+	}
+
+	if !ok {
+		return PurposeClaims{}, &PurposeError{Purpose: purpose, Err: ErrTokenUsed}
+	}
+
+	return PurposeClaims{
+		ID:        id,
+		Subject:   subject,
+		Purpose:   purpose,
+		ExpiresAt: expirationTime.Unix(),
+	}, nil
+}
@@ -0,0 +1,93 @@
+package authentication_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestPurposeTokens_Consume(t *testing.T) {
+	ctx := context.Background()
+	auth := authentication.Default("secret", 3600)
+	tokens := authentication.NewPurposeTokens(auth, nil)
+
+	tokenString, err := tokens.Issue("user-1", authentication.PurposePasswordReset, 15*time.Minute)
+	require.NoError(t, err)
+
+	expiredToken, err := tokens.Issue("user-1", authentication.PurposePasswordReset, -time.Minute)
+	require.NoError(t, err)
+
+	accessToken, err := auth.ClaimsSignedToken("user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	var purposeErr *authentication.PurposeError
+
+	_, err = tokens.Consume(ctx, tokenString, authentication.PurposeMagicLink)
+	require.ErrorAs(t, err, &purposeErr)
+	assert.Equal(t, authentication.PurposeMagicLink, purposeErr.Purpose)
+	assert.ErrorIs(t, err, authentication.ErrWrongPurpose)
+
+	claims, err := tokens.Consume(ctx, tokenString, authentication.PurposePasswordReset)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, authentication.PurposePasswordReset, claims.Purpose)
+
+	_, err = tokens.Consume(ctx, tokenString, authentication.PurposePasswordReset)
+	assert.ErrorIs(t, err, authentication.ErrTokenUsed)
+
+	_, err = tokens.Consume(ctx, expiredToken, authentication.PurposePasswordReset)
+	assert.ErrorIs(t, err, authentication.ErrTokenExpired)
+
+	_, err = tokens.Consume(ctx, accessToken, authentication.PurposePasswordReset)
+	assert.ErrorIs(t, err, authentication.ErrWrongPurpose)
+
+	_, err = tokens.Consume(ctx, "invalid-token", authentication.PurposePasswordReset)
+	assert.ErrorIs(t, err, authentication.ErrUnauthorized)
+}
+
+func TestPurposeTokens_ConcurrentConsume(t *testing.T) {
+	tokens := authentication.NewPurposeTokens(authentication.Default("secret", 3600), nil)
+
+	tokenString, err := tokens.Issue("user-1", authentication.PurposeMagicLink, time.Minute)
+	require.NoError(t, err)
+
+	var (
+		consumed atomic.Int32
+		wg       sync.WaitGroup
+	)
+
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := tokens.Consume(context.Background(), tokenString, authentication.PurposeMagicLink); err == nil {
+				consumed.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(1), consumed.Load())
+}
+
+func TestMiddleware_PurposeToken(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tokenString, err := authentication.NewPurposeTokens(auth, nil).Issue("user-1", authentication.PurposeMagicLink, time.Minute)
+	require.NoError(t, err)
+
+	rr, _, _ := serve(t, authentication.NewMiddleware(auth), httptest.NewRequest(http.MethodGet, "/user", nil), tokenString)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
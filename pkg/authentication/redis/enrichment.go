@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// DefaultEnrichmentKeyPrefix is the prefix of the Redis keys written by the EnrichmentCache, when no prefix is set.
+const DefaultEnrichmentKeyPrefix = DefaultKeyPrefix + "claims:"
+
+// EnrichmentCache is an authentication.EnrichmentCache that keeps user data in Redis, as JSON.
+type EnrichmentCache struct {
+	prefix string
+	redis  redis.UniversalClient
+}
+
+// NewEnrichmentCache is an EnrichmentCache constructor.
+//
+// prefix is the prefix of the Redis keys, which defaults to DefaultEnrichmentKeyPrefix when empty.
+func NewEnrichmentCache(redisClient redis.UniversalClient, prefix string) *EnrichmentCache {
+	if prefix == "" {
+		prefix = DefaultEnrichmentKeyPrefix
+	}
+
+	return &EnrichmentCache{
+		prefix: prefix,
+		redis:  redisClient,
+	}
+}
+
+// Get returns the cached user data of the key, and reports whether it was found.
+func (c *EnrichmentCache) Get(ctx context.Context, key string) (authentication.Enrichment, bool, error) {
+	data, err := c.redis.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return authentication.Enrichment{}, false, nil
+		}
+
+		return authentication.Enrichment{}, false, err
+	}
+
+	var enrichment authentication.Enrichment
+
+	if err = json.Unmarshal(data, &enrichment); err != nil {
+		return authentication.Enrichment{}, false, err
+	}
+
+	return enrichment, true, nil
+}
+
+// Set caches the user data of the key for the given duration.
+func (c *EnrichmentCache) Set(
+	ctx context.Context,
+	key string,
+	enrichment authentication.Enrichment,
+	ttl time.Duration,
+) error {
+	data, err := json.Marshal(enrichment)
+	if err != nil {
+		return err
+	}
+
+	return c.redis.Set(ctx, c.prefix+key, data, ttl).Err()
+}
+
+// Delete removes the cached user data of the key.
+func (c *EnrichmentCache) Delete(ctx context.Context, key string) error {
+	return c.redis.Del(ctx, c.prefix+key).Err()
+}
@@ -0,0 +1,259 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis is a Redis client hook that runs the commands used by the Store in memory, without a server.
+type fakeRedis struct {
+	mutex    sync.Mutex
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	expireAt map[string]time.Time
+}
+
+// newFakeRedis returns a Redis client backed by a fakeRedis.
+func newFakeRedis() (*redis.Client, *fakeRedis) {
+	fake := &fakeRedis{
+		strings:  map[string]string{},
+		hashes:   map[string]map[string]string{},
+		expireAt: map[string]time.Time{},
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "fake:6379"})
+	client.AddHook(fake)
+
+	return client, fake
+}
+
+func (f *fakeRedis) DialHook(_ redis.DialHook) redis.DialHook {
+	return func(_ context.Context, _, _ string) (net.Conn, error) {
+		return nil, fmt.Errorf("fake redis doesn't dial")
+	}
+}
+
+func (f *fakeRedis) ProcessHook(_ redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+
+		f.process(cmd)
+
+		return cmd.Err()
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(_ redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(_ context.Context, cmds []redis.Cmder) error {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+
+		for _, cmd := range cmds {
+			f.process(cmd)
+		}
+
+		return nil
+	}
+}
+
+// keys returns the stored keys which haven't expired.
+func (f *fakeRedis) keys() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var keys []string
+
+	for key := range f.strings {
+		if f.exists(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	for key := range f.hashes {
+		if f.exists(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// ttl returns the remaining time to live of a key, or zero when it doesn't expire.
+func (f *fakeRedis) ttl(key string) time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if expireAt, ok := f.expireAt[key]; ok {
+		return time.Until(expireAt)
+	}
+
+	return 0
+}
+
+func (f *fakeRedis) exists(key string) bool {
+	if expireAt, ok := f.expireAt[key]; ok && !time.Now().Before(expireAt) {
+		f.delete(key)
+	}
+
+	_, isString := f.strings[key]
+	_, isHash := f.hashes[key]
+
+	return isString || isHash
+}
+
+func (f *fakeRedis) delete(key string) {
+	delete(f.strings, key)
+	delete(f.hashes, key)
+	delete(f.expireAt, key)
+}
+
+func (f *fakeRedis) expire(key, ttl string, unit time.Duration) {
+	duration, _ := strconv.Atoi(ttl)
+	f.expireAt[key] = time.Now().Add(time.Duration(duration) * unit)
+}
+
+func (f *fakeRedis) process(cmd redis.Cmder) {
+	args := make([]string, len(cmd.Args()))
+
+	for i, arg := range cmd.Args() {
+		if value, ok := arg.([]byte); ok {
+			args[i] = string(value)
+			continue
+		}
+
+		args[i] = fmt.Sprint(arg)
+	}
+
+	name := strings.ToLower(args[0])
+	if name == "multi" || name == "exec" {
+		return
+	}
+
+	switch name {
+	case "set":
+		onlyNew := slices.Contains(args[3:], "nx")
+		if onlyNew && f.exists(args[1]) {
+			cmd.(*redis.BoolCmd).SetVal(false)
+			return
+		}
+
+		f.delete(args[1])
+		f.strings[args[1]] = args[2]
+
+		if len(args) >= 5 {
+			unit := time.Second
+			if strings.ToLower(args[3]) == "px" {
+				unit = time.Millisecond
+			}
+
+			f.expire(args[1], args[4], unit)
+		}
+
+		if onlyNew {
+			cmd.(*redis.BoolCmd).SetVal(true)
+			return
+		}
+
+		cmd.(*redis.StatusCmd).SetVal("OK")
+	case "get":
+		if !f.exists(args[1]) {
+			cmd.SetErr(redis.Nil)
+			return
+		}
+
+		cmd.(*redis.StringCmd).SetVal(f.strings[args[1]])
+	case "del", "exists":
+		var count int64
+
+		for _, key := range args[1:] {
+			if f.exists(key) {
+				count++
+
+				if name == "del" {
+					f.delete(key)
+				}
+			}
+		}
+
+		cmd.(*redis.IntCmd).SetVal(count)
+	case "hset":
+		if !f.exists(args[1]) {
+			f.hashes[args[1]] = map[string]string{}
+		}
+
+		var count int64
+
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := f.hashes[args[1]][args[i]]; !ok {
+				count++
+			}
+
+			f.hashes[args[1]][args[i]] = args[i+1]
+		}
+
+		cmd.(*redis.IntCmd).SetVal(count)
+	case "hget":
+		value, ok := f.hashes[args[1]][args[2]]
+		if !f.exists(args[1]) || !ok {
+			cmd.SetErr(redis.Nil)
+			return
+		}
+
+		cmd.(*redis.StringCmd).SetVal(value)
+	case "hgetall":
+		values := map[string]string{}
+
+		if f.exists(args[1]) {
+			maps.Copy(values, f.hashes[args[1]])
+		}
+
+		cmd.(*redis.MapStringStringCmd).SetVal(values)
+	case "hdel":
+		var count int64
+
+		if f.exists(args[1]) {
+			for _, field := range args[2:] {
+				if _, ok := f.hashes[args[1]][field]; ok {
+					delete(f.hashes[args[1]], field)
+					count++
+				}
+			}
+
+			if len(f.hashes[args[1]]) == 0 {
+				f.delete(args[1])
+			}
+		}
+
+		cmd.(*redis.IntCmd).SetVal(count)
+	case "pttl":
+		switch expireAt, ok := f.expireAt[args[1]]; {
+		case !f.exists(args[1]):
+			cmd.(*redis.DurationCmd).SetVal(-2)
+		case !ok:
+			cmd.(*redis.DurationCmd).SetVal(-1)
+		default:
+			cmd.(*redis.DurationCmd).SetVal(time.Until(expireAt))
+		}
+	case "pexpire":
+		ok := f.exists(args[1])
+		if ok {
+			f.expire(args[1], args[2], time.Millisecond)
+		}
+
+		cmd.(*redis.BoolCmd).SetVal(ok)
+	default:
+		cmd.SetErr(fmt.Errorf("fake redis doesn't support %s", name))
+	}
+}
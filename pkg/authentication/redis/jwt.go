@@ -2,68 +2,38 @@ package jwt
 
 import (
 	"context"
-	"fmt"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/redis/go-redis/v9"
+	"net/http"
 
 	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
 )
 
 // GetClaims allows to extract claims from context.
 func (j *JWT) GetClaims(ctx context.Context) (authentication.Claims, error) {
-	claims, err := j.auth.ParseClaims(ctx)
-	if err != nil {
-		return authentication.Claims{}, err
-	}
-
-	if j.redis != nil {
-		_, err = j.redis.Get(ctx, claims.ID).Result()
-		if err != nil {
-			if err == redis.Nil {
-				return authentication.Claims{}, fmt.Errorf("key does not exist: %v", err)
-			}
-
-			return authentication.Claims{}, fmt.Errorf("redis error: %v", err)
-		}
-	}
-
-	return claims, err
+	return j.middleware.GetClaims(ctx)
 }
 
 // Logout removes claims from the context, effectively logging the user out.
 func (j *JWT) Logout(ctx context.Context) context.Context {
-	if j.redis != nil {
-		claims, ok := ctx.Value(j.auth.ClaimsKey).(*authentication.Claims)
-		if ok && claims != nil && claims.ExpiresAt > 0 {
-			remainingTTL := time.Until(time.Unix(claims.ExpiresAt, 0))
-
-			if remainingTTL > 0 {
-				j.redis.Del(ctx, claims.ID)
-			}
-		}
-	}
-
-	return context.WithValue(ctx, j.auth.ClaimsKey, nil)
+	return j.middleware.Logout(ctx)
 }
 
+// Login issues a signed token for the given user data.
 func (j *JWT) Login(ctx context.Context, subject, issuer, audience, role string) (string, error) {
-	claims := authentication.NewMapClaims(subject, issuer, audience, role, j.auth.TokenDuration)
-
-	token := jwt.NewWithClaims(j.auth.SigningMethod, claims)
+	return j.middleware.Login(ctx, subject, issuer, audience, role)
+}
 
-	tokenString, err := token.SignedString([]byte(j.auth.ClaimsKey))
-	if err != nil {
-		return "", fmt.Errorf("signing claims token failed: %v", err)
-	}
+// Exchange swaps a subject token for a downscoped token, when token exchange is enabled.
+// It implements authentication.TokenExchanger, so the authentication handler mounts its endpoint.
+func (j *JWT) Exchange(ctx context.Context, request authentication.ExchangeRequest) (string, []string, error) {
+	return j.middleware.Exchange(ctx, request)
+}
 
-	if j.redis != nil {
-		err = j.redis.Set(ctx, claims["id"].(string), tokenString, j.auth.TokenDuration).Err()
-		if err != nil {
-			return "", fmt.Errorf("redis set failed: %v", err)
-		}
-	}
+// TenantContext returns the request context with the request tenant, when multi-tenancy is used.
+func (j *JWT) TenantContext(r *http.Request) (context.Context, error) {
+	return j.middleware.TenantContext(r)
+}
 
-	return tokenString, nil
+// Authentication returns the authentication middleware, for the features this adapter doesn't expose.
+func (j *JWT) Authentication() *authentication.Middleware {
+	return j.middleware
 }
@@ -0,0 +1,62 @@
+package jwt
+
+// DefaultKeyPrefix is the prefix of the denylist and subject index keys written by the Store, when no key prefix or
+// schema is set, and of the other keys written by this package.
+const DefaultKeyPrefix = "auth:"
+
+// KeySchema names the Redis keys written by the Store.
+//
+// Keys used together in multi-key operations must hash to the same Redis Cluster slot, so they should share
+// a hash tag: the session and denylist keys of a token by its ID, and the subject index keys by subject.
+type KeySchema interface {
+	// Session is the key of a token session.
+	Session(id string) string
+	// Denylist is the key of a revoked token.
+	Denylist(id string) string
+	// SubjectIndex is the key of the sessions index of a subject.
+	SubjectIndex(subject string) string
+}
+
+// DefaultKeySchema is the KeySchema used when no key prefix or schema is set.
+//
+// Sessions are kept at their token ID, as previous versions did, so existing sessions are still found after an
+// upgrade. Denylist and subject index keys are prefixed with DefaultKeyPrefix. Session keys are hashed as a whole,
+// so they share the Redis Cluster slot of their denylist key, whose hash tag is the token ID.
+type DefaultKeySchema struct{}
+
+// Session is the key of a token session.
+func (DefaultKeySchema) Session(id string) string {
+	return id
+}
+
+// Denylist is the key of a revoked token.
+func (DefaultKeySchema) Denylist(id string) string {
+	return PrefixKeySchema{Prefix: DefaultKeyPrefix}.Denylist(id)
+}
+
+// SubjectIndex is the key of the sessions index of a subject.
+func (DefaultKeySchema) SubjectIndex(subject string) string {
+	return PrefixKeySchema{Prefix: DefaultKeyPrefix}.SubjectIndex(subject)
+}
+
+// PrefixKeySchema is a KeySchema that prefixes every key and uses the token ID or subject as hash tag.
+//
+// The prefix must not contain braces, so it doesn't change the key hash tags.
+type PrefixKeySchema struct {
+	Prefix string
+}
+
+// Session is the key of a token session.
+func (s PrefixKeySchema) Session(id string) string {
+	return s.Prefix + "session:{" + id + "}"
+}
+
+// Denylist is the key of a revoked token.
+func (s PrefixKeySchema) Denylist(id string) string {
+	return s.Prefix + "denylist:{" + id + "}"
+}
+
+// SubjectIndex is the key of the sessions index of a subject.
+func (s PrefixKeySchema) SubjectIndex(subject string) string {
+	return s.Prefix + "subject:{" + subject + "}"
+}
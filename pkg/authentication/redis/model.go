@@ -1,22 +1,20 @@
 package jwt
 
 import (
+	"reflect"
+
 	"github.com/redis/go-redis/v9"
 
 	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
 )
 
-const (
-	expiredTokenMessage = "token has expired"
-	unauthorizedMessage = "unauthorized"
-)
-
-// JWT is a JWTMiddleware that holds authentication data and dependencies.
+// JWT is a JWTMiddleware that holds authentication data and dependencies, with Redis sessions.
+//
+// It is an adapter of the authentication middleware, which it builds once, with the given settings.
+// Its fields hold those settings, so changing them doesn't change the middleware.
 //
 // adminRole is the maximum permission role, that allows everything by default.
 // claimsKey is the authentication key used by claims.
-// tokenSecret the secret key to verify the integrity and authenticity of the JWT
-// tokenMaxAge is the max duration of a token, in nanoseconds.
 // skipList is the list of endpoints that are ignored for JWT verification.
 // permissionsMap is the list of endpoints, associated to the allowed permission roles.
 type JWT struct {
@@ -25,30 +23,51 @@ type JWT struct {
 	PermissionsMap map[string][]string
 	SkipList       []string
 
-	auth  authentication.Auth
-	redis *redis.Client
+	middleware *authentication.Middleware
 }
 
 // New is a JWT middleware constructor.
 //
 // adminRole is the maximum permission role, that allows everything by default.
-// claimsKey is the authentication key used by claims.
-// tokenSecret the secret key to verify the integrity and authenticity of the JWT
-// tokenMaxAge is the max duration of a token, in nanoseconds.
 // skipList is the list of endpoints that are ignored for JWT verification.
 // permissionsMap is the list of endpoints, associated to the allowed permission roles.
+// auth holds the claims key, signing method and token duration.
+// redisClient keeps the logged in token sessions, with the default key schema. Sessions aren't kept when it's nil.
+// options configure the remaining middleware features, such as token renewal or multi-tenancy.
 func New(
 	adminRole string,
 	skipList []string,
 	permissionsMap map[string][]string,
-	authentication authentication.Auth,
-	redisClient *redis.Client,
+	auth authentication.Auth,
+	redisClient redis.UniversalClient,
+	options ...authentication.Option,
 ) JWT {
+	defaults := []authentication.Option{
+		authentication.WithAdminRole(adminRole),
+		authentication.WithSkip(skipList...),
+		authentication.WithPermissions(permissionsMap),
+	}
+
+	if !isNil(redisClient) {
+		defaults = append(defaults, authentication.WithSessionStore(NewStore(redisClient)))
+	}
+
 	return JWT{
 		AdminRole:      adminRole,
+		ClaimsKey:      auth.ClaimsKey,
 		PermissionsMap: permissionsMap,
 		SkipList:       skipList,
-		auth:           authentication,
-		redis:          redisClient,
+		middleware:     authentication.NewMiddleware(auth, append(defaults, options...)...),
 	}
 }
+
+// isNil reports whether the client is nil, including nil client pointers.
+func isNil(redisClient redis.UniversalClient) bool {
+	if redisClient == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(redisClient)
+
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
@@ -0,0 +1,37 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultReplayKeyPrefix is the prefix of the Redis keys written by the ReplayStore, when no prefix is set.
+const DefaultReplayKeyPrefix = DefaultKeyPrefix + "used:"
+
+// ReplayStore is an authentication.ReplayStore that records single use values in Redis.
+type ReplayStore struct {
+	prefix string
+	redis  redis.UniversalClient
+}
+
+// NewReplayStore is a ReplayStore constructor.
+//
+// prefix is the prefix of the Redis keys, which defaults to DefaultReplayKeyPrefix when empty.
+func NewReplayStore(redisClient redis.UniversalClient, prefix string) *ReplayStore {
+	if prefix == "" {
+		prefix = DefaultReplayKeyPrefix
+	}
+
+	return &ReplayStore{
+		prefix: prefix,
+		redis:  redisClient,
+	}
+}
+
+// Use atomically records a value for the given duration, and reports whether it was unused.
+// A zero duration records the value permanently.
+func (s *ReplayStore) Use(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.redis.SetNX(ctx, s.prefix+key, 1, ttl).Result()
+}
@@ -0,0 +1,251 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// lastSeenInterval is the minimum interval between session last seen time updates, which limits Redis writes.
+const lastSeenInterval = time.Minute
+
+// Session is an active token session of a subject.
+type Session struct {
+	ID string `json:"id"`
+	authentication.SessionMetadata
+}
+
+// WithMaxSessions limits the number of concurrent sessions per subject.
+// When a new session exceeds the limit, the oldest sessions of the subject are revoked.
+func WithMaxSessions(maxSessions int) StoreOption {
+	return func(s *Store) {
+		s.maxSessions = maxSessions
+	}
+}
+
+// ListSessions returns the active sessions of a subject, from the oldest to the newest.
+//
+// Sessions that are no longer active are removed from the subject index.
+func (s *Store) ListSessions(ctx context.Context, subject string) ([]Session, error) {
+	key := s.keys.SubjectIndex(subject)
+
+	entries, err := s.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+
+	exists := make([]*redis.IntCmd, len(ids))
+
+	_, err = s.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			exists[i] = pipe.Exists(ctx, s.keys.Session(id))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sessions = make([]Session, 0, len(ids))
+		stale    []string
+	)
+
+	for i, id := range ids {
+		var metadata authentication.SessionMetadata
+
+		if exists[i].Val() == 0 || json.Unmarshal([]byte(entries[id]), &metadata) != nil {
+			stale = append(stale, id)
+			continue
+		}
+
+		sessions = append(sessions, Session{ID: id, SessionMetadata: metadata})
+	}
+
+	if len(stale) > 0 {
+		if err = s.redis.HDel(ctx, key, stale...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession removes a session of a subject, and denies its token for the session remaining lifetime,
+// so the middleware rejects it.
+// It returns authentication.ErrSessionNotFound when the session doesn't belong to the subject.
+func (s *Store) RevokeSession(ctx context.Context, subject, id string) error {
+	removed, err := s.redis.HDel(ctx, s.keys.SubjectIndex(subject), id).Result()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return authentication.ErrSessionNotFound
+	}
+
+	// Sessions last as long as their token, and an expired session has nothing left to deny.
+	ttl, err := s.redis.PTTL(ctx, s.keys.Session(id)).Result()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case ttl == -2:
+		return nil
+	case ttl < 0:
+		ttl = 0
+	}
+
+	return s.Deny(ctx, id, ttl)
+}
+
+// Touch updates the last seen time of a session of the subject.
+// It implements authentication.SessionTracker.
+//
+// Each session is checked in Redis at most once per interval by a Store, so most requests don't reach Redis.
+func (s *Store) Touch(ctx context.Context, subject, id string) error {
+	if !s.touchDue(id, time.Now()) {
+		return nil
+	}
+
+	key := s.keys.SubjectIndex(subject)
+
+	metadata, err := s.metadata(ctx, key, id)
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+
+	if err != nil || time.Since(metadata.LastSeenAt) < lastSeenInterval {
+		return err
+	}
+
+	metadata.LastSeenAt = time.Now()
+
+	return s.setMetadata(ctx, key, id, metadata)
+}
+
+// touchDue reports whether a session wasn't touched by this Store within the last seen interval, and records the
+// touch when it wasn't. Touches older than the interval are pruned once per interval.
+func (s *Store) touchDue(id string, now time.Time) bool {
+	s.touchMutex.Lock()
+	defer s.touchMutex.Unlock()
+
+	if now.Sub(s.prunedAt) >= lastSeenInterval {
+		for touchedID, touchedAt := range s.touches {
+			if now.Sub(touchedAt) >= lastSeenInterval {
+				delete(s.touches, touchedID)
+			}
+		}
+
+		s.prunedAt = now
+	}
+
+	if touchedAt, ok := s.touches[id]; ok && now.Sub(touchedAt) < lastSeenInterval {
+		return false
+	}
+
+	s.touches[id] = now
+
+	return true
+}
+
+// index adds a session to its subject index, keeping the metadata of the session it was renewed from,
+// and evicts the oldest sessions over the concurrent sessions limit.
+func (s *Store) index(ctx context.Context, id string, metadata authentication.SessionMetadata, ttl time.Duration) error {
+	key := s.keys.SubjectIndex(metadata.Subject)
+
+	if metadata.RenewedFrom != "" {
+		previous, err := s.metadata(ctx, key, metadata.RenewedFrom)
+		if err == nil {
+			metadata.CreatedAt = previous.CreatedAt
+
+			if metadata.Device == "" {
+				metadata.Device = previous.Device
+			}
+		} else if !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		if err = s.redis.HDel(ctx, key, metadata.RenewedFrom).Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.setMetadata(ctx, key, id, metadata); err != nil {
+		return err
+	}
+
+	// The index lasts as long as its longest session.
+	indexTTL, err := s.redis.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	if indexTTL < ttl {
+		if err = s.redis.PExpire(ctx, key, ttl).Err(); err != nil {
+			return err
+		}
+	}
+
+	if s.maxSessions <= 0 {
+		return nil
+	}
+
+	sessions, err := s.ListSessions(ctx, metadata.Subject)
+	if err != nil {
+		return err
+	}
+
+	// The new session is always kept, so the oldest of the other sessions are evicted.
+	sessions = slices.DeleteFunc(sessions, func(session Session) bool {
+		return session.ID == id
+	})
+
+	for i := 0; i < len(sessions)-(s.maxSessions-1); i++ {
+		if err = s.RevokeSession(ctx, metadata.Subject, sessions[i].ID); err != nil {
+			return fmt.Errorf("session eviction failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) metadata(ctx context.Context, key, id string) (authentication.SessionMetadata, error) {
+	var metadata authentication.SessionMetadata
+
+	value, err := s.redis.HGet(ctx, key, id).Result()
+	if err != nil {
+		return metadata, err
+	}
+
+	err = json.Unmarshal([]byte(value), &metadata)
+
+	return metadata, err
+}
+
+func (s *Store) setMetadata(ctx context.Context, key, id string, metadata authentication.SessionMetadata) error {
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	return s.redis.HSet(ctx, key, id, value).Err()
+}
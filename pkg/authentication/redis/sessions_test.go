@@ -0,0 +1,290 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// login issues a token from the given device, and returns its session ID.
+func login(t *testing.T, middleware authentication.JWT, device string) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set("User-Agent", "test-agent")
+
+	ctx := authentication.ContextWithSessionMetadata(req.Context(), authentication.RequestSessionMetadata(req, device))
+
+	tokenString, err := middleware.Login(ctx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	auth := authentication.Default("secret", 3600)
+
+	claims, err := auth.ParseToken(tokenString)
+	require.NoError(t, err)
+
+	return (*claims)["id"].(string)
+}
+
+func TestStore_ListSessions(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedis()
+
+	store := NewStore(client)
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600), authentication.WithSessionStore(store))
+
+	laptop := login(t, middleware, "laptop")
+	time.Sleep(time.Millisecond)
+	phone := login(t, middleware, "phone")
+
+	sessions, err := store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	assert.Equal(t, laptop, sessions[0].ID)
+	assert.Equal(t, "laptop", sessions[0].Device)
+	assert.Equal(t, "user-1", sessions[0].Subject)
+	assert.Equal(t, "192.0.2.1", sessions[0].IP)
+	assert.Equal(t, "test-agent", sessions[0].UserAgent)
+	assert.WithinDuration(t, time.Now(), sessions[0].CreatedAt, time.Second)
+	assert.Equal(t, sessions[0].CreatedAt, sessions[0].LastSeenAt)
+	assert.Equal(t, phone, sessions[1].ID)
+
+	assert.InDelta(t, time.Hour, fake.ttl(DefaultKeyPrefix+"subject:{user-1}"), float64(time.Second))
+
+	// Logged out sessions are removed from the subject index.
+	_, err = store.Delete(ctx, laptop)
+	require.NoError(t, err)
+
+	sessions, err = store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, phone, sessions[0].ID)
+
+	sessions, err = store.ListSessions(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestStore_RevokeSession(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	store := NewStore(client)
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600), authentication.WithSessionStore(store))
+
+	id := login(t, middleware, "laptop")
+
+	assert.ErrorIs(t, store.RevokeSession(ctx, "user-2", id), authentication.ErrSessionNotFound)
+
+	ok, err := store.Exists(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, store.RevokeSession(ctx, "user-1", id))
+
+	ok, err = store.Exists(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, store.RevokeSession(ctx, "user-1", id), authentication.ErrSessionNotFound)
+}
+
+func TestStore_RevokedToken(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedis()
+
+	store := NewStore(client, WithMaxSessions(2))
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600), authentication.WithSessionStore(store))
+
+	serve := func(tokenString string) int {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+
+		rr := httptest.NewRecorder()
+		middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	metadataCtx := authentication.ContextWithSessionMetadata(ctx, authentication.SessionMetadata{Device: "laptop"})
+
+	revokedToken, err := middleware.Login(metadataCtx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	evictedToken, err := middleware.Login(metadataCtx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, serve(revokedToken))
+
+	auth := authentication.Default("secret", 3600)
+
+	claims, err := auth.ParseToken(revokedToken)
+	require.NoError(t, err)
+
+	id := (*claims)["id"].(string)
+
+	// Revoked tokens are denied for their remaining lifetime, so handlers that don't read claims aren't served.
+	require.NoError(t, store.RevokeSession(ctx, "user-1", id))
+	assert.Equal(t, http.StatusUnauthorized, serve(revokedToken))
+	assert.InDelta(t, time.Hour, fake.ttl(DefaultKeySchema{}.Denylist(id)), float64(time.Minute))
+
+	// Evicted sessions are revoked as well.
+	time.Sleep(time.Millisecond)
+	login(t, middleware, "phone")
+	time.Sleep(time.Millisecond)
+	login(t, middleware, "tablet")
+
+	assert.Equal(t, http.StatusUnauthorized, serve(evictedToken))
+}
+
+func TestStore_MaxSessions(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	store := NewStore(client, WithMaxSessions(2))
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600), authentication.WithSessionStore(store))
+
+	oldest := login(t, middleware, "laptop")
+	time.Sleep(time.Millisecond)
+	login(t, middleware, "phone")
+	time.Sleep(time.Millisecond)
+	login(t, middleware, "tablet")
+
+	sessions, err := store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "phone", sessions[0].Device)
+	assert.Equal(t, "tablet", sessions[1].Device)
+
+	ok, err := store.Exists(ctx, oldest)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestStore_MaxSessionsRenewal(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	store := NewStore(client, WithMaxSessions(1))
+	middleware := authentication.NewMiddleware(
+		authentication.Default("secret", 3600),
+		authentication.WithSessionStore(store),
+		authentication.WithRenewal(authentication.Renewal{Window: 2 * time.Hour}),
+	)
+
+	laptop := login(t, middleware, "laptop")
+	time.Sleep(time.Millisecond)
+	phone := login(t, middleware, "phone")
+
+	tokenString, err := client.Get(ctx, phone).Result()
+	require.NoError(t, err)
+
+	ok, err := store.Exists(ctx, laptop)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// A newer laptop session, as saved by a concurrent login.
+	require.NoError(t, client.Set(ctx, laptop, "signed-token", time.Hour).Err())
+	require.NoError(t, store.setMetadata(ctx, DefaultKeyPrefix+"subject:{user-1}", laptop,
+		authentication.SessionMetadata{Subject: "user-1", Device: "laptop", CreatedAt: time.Now()}))
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	rr := httptest.NewRecorder()
+	middleware.Middleware(http.NotFoundHandler()).ServeHTTP(rr, req)
+	require.NotEmpty(t, rr.Header().Get(authentication.DefaultRenewalHeader))
+
+	// The renewed session keeps the creation time of the phone session, which is older than the laptop one,
+	// and it is still the only session kept.
+	sessions, err := store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "phone", sessions[0].Device)
+}
+
+func TestStore_Renewal(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	store := NewStore(client)
+	middleware := authentication.NewMiddleware(
+		authentication.Default("secret", 3600),
+		authentication.WithSessionStore(store),
+		authentication.WithRenewal(authentication.Renewal{Window: 2 * time.Hour}),
+	)
+
+	id := login(t, middleware, "laptop")
+
+	sessions, err := store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	createdAt := sessions[0].CreatedAt
+
+	tokenString, err := client.Get(ctx, id).Result()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	rr := httptest.NewRecorder()
+	middleware.Middleware(http.NotFoundHandler()).ServeHTTP(rr, req)
+	require.NotEmpty(t, rr.Header().Get(authentication.DefaultRenewalHeader))
+
+	// The renewed session replaces the previous one, and keeps its metadata.
+	sessions, err = store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.NotEqual(t, id, sessions[0].ID)
+	assert.Equal(t, "laptop", sessions[0].Device)
+	assert.Equal(t, createdAt, sessions[0].CreatedAt)
+}
+
+func TestStore_Touch(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	store := NewStore(client)
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600), authentication.WithSessionStore(store))
+
+	id := login(t, middleware, "laptop")
+
+	// The last seen time is only updated after the minimum interval.
+	require.NoError(t, store.Touch(ctx, "user-1", id))
+
+	sessions, err := store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, sessions[0].CreatedAt, sessions[0].LastSeenAt)
+
+	metadata := sessions[0].SessionMetadata
+	metadata.LastSeenAt = metadata.LastSeenAt.Add(-time.Hour)
+	require.NoError(t, store.setMetadata(ctx, DefaultKeyPrefix+"subject:{user-1}", id, metadata))
+
+	// Sessions touched by the store within the interval don't reach Redis.
+	require.NoError(t, store.Touch(ctx, "user-1", id))
+
+	sessions, err = store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, metadata.LastSeenAt, sessions[0].LastSeenAt)
+
+	// Another store doesn't share the touches of this one.
+	require.NoError(t, NewStore(client).Touch(ctx, "user-1", id))
+
+	sessions, err = store.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), sessions[0].LastSeenAt, time.Second)
+
+	// Unknown sessions are ignored.
+	assert.NoError(t, store.Touch(ctx, "user-1", "unknown"))
+}
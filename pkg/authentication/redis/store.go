@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// Store is an authentication.SessionStore that keeps token sessions in Redis.
+//
+// It accepts any redis.UniversalClient, so it works with single node, Sentinel, Cluster and ring setups.
+// Sessions saved with authentication.SessionMetadata in context are also indexed by subject,
+// so they can be listed and revoked by their owner.
+type Store struct {
+	keys        KeySchema
+	maxSessions int
+	redis       redis.UniversalClient
+
+	touchMutex sync.Mutex
+	touches    map[string]time.Time
+	prunedAt   time.Time
+}
+
+// StoreOption configures a Store.
+type StoreOption func(s *Store)
+
+// WithKeyPrefix sets the prefix of the default key schema.
+func WithKeyPrefix(prefix string) StoreOption {
+	return func(s *Store) {
+		s.keys = PrefixKeySchema{Prefix: prefix}
+	}
+}
+
+// WithKeySchema sets how the Redis keys are named.
+func WithKeySchema(schema KeySchema) StoreOption {
+	return func(s *Store) {
+		s.keys = schema
+	}
+}
+
+// NewStore is a Store constructor.
+//
+// Keys are named by DefaultKeySchema, unless another key prefix or schema is set.
+func NewStore(redisClient redis.UniversalClient, options ...StoreOption) *Store {
+	s := &Store{
+		keys:    DefaultKeySchema{},
+		redis:   redisClient,
+		touches: map[string]time.Time{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Save stores the session of a token, for the given duration.
+// The session is added to its subject index when the context holds its metadata.
+func (s *Store) Save(ctx context.Context, id, token string, ttl time.Duration) error {
+	err := s.redis.Set(ctx, s.keys.Session(id), token, ttl).Err()
+	if err != nil {
+		return err
+	}
+
+	metadata, ok := authentication.SessionMetadataFromContext(ctx)
+	if !ok || metadata.Subject == "" {
+		return nil
+	}
+
+	return s.index(ctx, id, metadata, ttl)
+}
+
+// Exists reports whether a session is still active.
+func (s *Store) Exists(ctx context.Context, id string) (bool, error) {
+	err := s.redis.Get(ctx, s.keys.Session(id)).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Delete removes a session, and reports whether it was active.
+// Its subject index entry is removed when the subject sessions are listed.
+func (s *Store) Delete(ctx context.Context, id string) (bool, error) {
+	deleted, err := s.redis.Del(ctx, s.keys.Session(id)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// Deny atomically removes a session and adds its token to the denylist, for the given duration.
+// The duration should be the remaining lifetime of the token.
+func (s *Store) Deny(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.keys.Session(id))
+		pipe.Set(ctx, s.keys.Denylist(id), 1, ttl)
+
+		return nil
+	})
+
+	return err
+}
+
+// Denied reports whether a token is in the denylist.
+// It implements authentication.TokenDenylist, so the middleware rejects denied tokens.
+func (s *Store) Denied(ctx context.Context, id string) (bool, error) {
+	denied, err := s.redis.Exists(ctx, s.keys.Denylist(id)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return denied > 0, nil
+}
@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// hashTag returns the part of a key hashed by Redis Cluster.
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+
+	return key[start+1 : start+1+end]
+}
+
+func TestPrefixKeySchema(t *testing.T) {
+	schema := PrefixKeySchema{Prefix: "app:"}
+
+	assert.Equal(t, "app:session:{token-1}", schema.Session("token-1"))
+	assert.Equal(t, "app:denylist:{token-1}", schema.Denylist("token-1"))
+	assert.Equal(t, "app:subject:{user-1}", schema.SubjectIndex("user-1"))
+
+	assert.Equal(t, hashTag(schema.Session("token-1")), hashTag(schema.Denylist("token-1")))
+}
+
+func TestDefaultKeySchema(t *testing.T) {
+	schema := DefaultKeySchema{}
+
+	assert.Equal(t, "token-1", schema.Session("token-1"))
+	assert.Equal(t, "auth:denylist:{token-1}", schema.Denylist("token-1"))
+	assert.Equal(t, "auth:subject:{user-1}", schema.SubjectIndex("user-1"))
+
+	assert.Equal(t, hashTag(schema.Session("token-1")), hashTag(schema.Denylist("token-1")))
+}
+
+func TestStore_DefaultKeys(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	// Sessions saved by previous versions are kept at their token ID.
+	require.NoError(t, client.Set(ctx, "token-1", "signed-token", time.Minute).Err())
+
+	ok, err := NewStore(client).Exists(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedis()
+
+	store := NewStore(client, WithKeyPrefix("app:"))
+
+	require.NoError(t, store.Save(ctx, "token-1", "signed-token", time.Minute))
+	assert.Equal(t, []string{"app:session:{token-1}"}, fake.keys())
+
+	ok, err := store.Exists(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	deleted, err := store.Delete(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	ok, err = store.Exists(ctx, "token-1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	deleted, err = store.Delete(ctx, "token-1")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestStore_Deny(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedis()
+
+	store := NewStore(client)
+
+	require.NoError(t, store.Save(ctx, "token-1", "signed-token", time.Minute))
+	require.NoError(t, store.Deny(ctx, "token-1", time.Minute))
+
+	assert.Equal(t, []string{DefaultKeyPrefix + "denylist:{token-1}"}, fake.keys())
+
+	denied, err := store.Denied(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, denied)
+
+	denied, err = store.Denied(ctx, "token-2")
+	require.NoError(t, err)
+	assert.False(t, denied)
+}
+
+func TestStore_DeniedToken(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis()
+
+	auth := authentication.Default("secret", 3600)
+	store := NewStore(client)
+	middleware := New("admin", nil, nil, auth, client)
+
+	tokenString, err := middleware.Login(ctx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	claims, err := auth.ParseToken(tokenString)
+	require.NoError(t, err)
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+
+		rr := httptest.NewRecorder()
+		middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+
+	require.NoError(t, store.Deny(ctx, (*claims)["id"].(string), time.Hour))
+	assert.Equal(t, http.StatusUnauthorized, serve())
+}
+
+func TestNew_NilClient(t *testing.T) {
+	var client *redis.Client
+
+	// A nil client pointer doesn't keep sessions, as a nil client.
+	middleware := New("admin", nil, nil, authentication.Default("secret", 3600), client)
+
+	_, err := middleware.Login(context.Background(), "user-1", "issuer", "audience", "user")
+	assert.NoError(t, err)
+}
+
+func TestReplayStore(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedis()
+
+	store := NewReplayStore(client, "")
+
+	ok, err := store.Use(ctx, "code-1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.Use(ctx, "code-1", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{DefaultReplayKeyPrefix + "code-1"}, fake.keys())
+	assert.InDelta(t, time.Minute, fake.ttl(DefaultReplayKeyPrefix+"code-1"), float64(time.Second))
+}
+
+func TestEnrichmentCache(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newFakeRedis()
+
+	cache := NewEnrichmentCache(client, "")
+	enrichment := authentication.Enrichment{
+		Permissions: []string{"orders:read"},
+		Groups:      []string{"support"},
+		Features:    map[string]bool{"beta": true},
+	}
+
+	_, ok, err := cache.Get(ctx, "user-1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Set(ctx, "user-1", enrichment, time.Minute))
+	assert.InDelta(t, time.Minute, fake.ttl(DefaultEnrichmentKeyPrefix+"user-1"), float64(time.Second))
+
+	cached, ok, err := cache.Get(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, enrichment, cached)
+
+	require.NoError(t, cache.Delete(ctx, "user-1"))
+	assert.Empty(t, fake.keys())
+}
@@ -0,0 +1,227 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRenewalHeader is the response header used for renewed tokens when no header or cookie is configured.
+	DefaultRenewalHeader = "X-Renewed-Token"
+	// DefaultRenewalGracePeriod is how long a renewed token stays active when no grace period is set.
+	DefaultRenewalGracePeriod = 30 * time.Second
+
+	originalIssuedAtClaim = "orig_iat"
+	// expirationLimitClaim is the latest expiration time of a token, which renewals don't extend.
+	expirationLimitClaim = "max_exp"
+)
+
+// Renewal configures sliding session expiration.
+//
+// Window is the time before "exp" in which a valid token is re-issued. Zero disables renewal.
+// MaxLifetime is the absolute session lifetime, counted from the first login. Zero means no limit.
+// Header is the response header that holds the renewed token.
+// Cookie is the name of the cookie that holds the renewed token. It is also read when no Authorization header is sent.
+// GracePeriod is how long a renewed token stays active, so concurrent requests sent with it still succeed and get
+// the same renewed token, before it is revoked. Zero uses DefaultRenewalGracePeriod.
+type Renewal struct {
+	Window      time.Duration
+	MaxLifetime time.Duration
+	Header      string
+	Cookie      string
+	GracePeriod time.Duration
+}
+
+// Enabled reports whether sliding session expiration is configured.
+func (r Renewal) Enabled() bool {
+	return r.Window > 0
+}
+
+// due reports whether the claims expire within the renewal window.
+func (r Renewal) due(claims jwt.MapClaims) bool {
+	if !r.Enabled() {
+		return false
+	}
+
+	expirationTime, err := claims.GetExpirationTime()
+	if err != nil || expirationTime == nil {
+		return false
+	}
+
+	return time.Until(expirationTime.Time) <= r.Window
+}
+
+// gracePeriod returns how long the session of a renewed token stays active.
+func (r Renewal) gracePeriod() time.Duration {
+	if r.GracePeriod <= 0 {
+		return DefaultRenewalGracePeriod
+	}
+
+	return r.GracePeriod
+}
+
+// WriteToken sends a renewed token to the client, in the configured header and cookie.
+func (r Renewal) WriteToken(w http.ResponseWriter, token string, expiresAt time.Time) {
+	if r.Header == "" && r.Cookie == "" {
+		w.Header().Set(DefaultRenewalHeader, token)
+		return
+	}
+
+	if r.Header != "" {
+		w.Header().Set(r.Header, token)
+	}
+
+	if r.Cookie != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     r.Cookie,
+			Value:    token,
+			Path:     "/",
+			Expires:  expiresAt,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// RenewClaims returns a copy of the claims with a new ID and expiration time, when they are within the
+// renewal window. The new expiration never exceeds the session maximum lifetime, nor the expiration limit of
+// exchanged tokens.
+func (a *Auth) RenewClaims(claims jwt.MapClaims, renewal Renewal) (jwt.MapClaims, bool) {
+	if !renewal.due(claims) {
+		return nil, false
+	}
+
+	expirationTime, _ := claims.GetExpirationTime()
+	now := time.Now()
+
+	originalIssuedAt, ok := numericClaim(claims, originalIssuedAtClaim)
+	if !ok {
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil {
+			return nil, false
+		}
+
+		originalIssuedAt = issuedAt.Time
+	}
+
+	expiresAt := now.Add(a.TokenDuration)
+
+	if renewal.MaxLifetime > 0 {
+		limit := originalIssuedAt.Add(renewal.MaxLifetime)
+		if expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+
+	if limit, ok := numericClaim(claims, expirationLimitClaim); ok && expiresAt.After(limit) {
+		expiresAt = limit
+	}
+
+	// Nothing to gain when the session has reached its maximum lifetime.
+	if !expiresAt.After(expirationTime.Time) {
+		return nil, false
+	}
+
+	// Numeric claims are stored as float64, as in parsed tokens, so the renewed claims can be stored in context.
+	renewed := maps.Clone(claims)
+	renewed["id"] = uuid.NewString()
+	renewed["iat"] = float64(now.Unix())
+	renewed["exp"] = float64(expiresAt.Unix())
+	renewed[originalIssuedAtClaim] = float64(originalIssuedAt.Unix())
+
+	return renewed, true
+}
+
+// successor is the token issued to renew another one.
+type successor struct {
+	once      sync.Once
+	claims    jwt.MapClaims
+	token     string
+	expiresAt time.Time
+}
+
+// successors holds the successors of the tokens renewed within their grace period, by renewed token ID, so
+// concurrent requests that renew the same token get the same successor.
+type successors struct {
+	mutex    sync.Mutex
+	tokens   map[string]*successor
+	prunedAt time.Time
+}
+
+func newSuccessors() *successors {
+	return &successors{
+		tokens: map[string]*successor{},
+	}
+}
+
+// get returns the successor of a token, which is added when there's none, and kept for the grace period.
+// Successors older than the grace period are pruned once per grace period.
+func (s *successors) get(id string, gracePeriod time.Duration) *successor {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(s.prunedAt) >= gracePeriod {
+		for tokenID, next := range s.tokens {
+			if !now.Before(next.expiresAt) {
+				delete(s.tokens, tokenID)
+			}
+		}
+
+		s.prunedAt = now
+	}
+
+	next, ok := s.tokens[id]
+	if !ok || !now.Before(next.expiresAt) {
+		next = &successor{expiresAt: now.Add(gracePeriod)}
+		s.tokens[id] = next
+	}
+
+	return next
+}
+
+// forget removes the successor of a token, when it wasn't issued, so the token renewal can be tried again.
+func (s *successors) forget(id string, next *successor) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.tokens[id] == next {
+		delete(s.tokens, id)
+	}
+}
+
+type originalIssuedAtKey struct{}
+
+// ContextWithOriginalIssuedAt stores the session start in context, so Login keeps it when a token is re-issued,
+// such as on refresh or after multi-factor authentication, and the session maximum lifetime still applies.
+func ContextWithOriginalIssuedAt(ctx context.Context, originalIssuedAt time.Time) context.Context {
+	return context.WithValue(ctx, originalIssuedAtKey{}, originalIssuedAt)
+}
+
+// numericClaim reads a Unix timestamp claim.
+func numericClaim(claims jwt.MapClaims, key string) (time.Time, bool) {
+	switch value := claims[key].(type) {
+	case float64:
+		return time.Unix(int64(value), 0), true
+	case int64:
+		return time.Unix(value, 0), true
+	case json.Number:
+		v, err := value.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return time.Unix(v, 0), true
+	default:
+		return time.Time{}, false
+	}
+}
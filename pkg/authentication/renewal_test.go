@@ -0,0 +1,141 @@
+package authentication_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestAuth_RenewClaims(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		renewal         authentication.Renewal
+		issuedAt        time.Time
+		expiresAt       time.Time
+		expirationLimit time.Time
+		expectedRenewed bool
+		expectedExpires time.Time
+	}{
+		{
+			name:            "Renewal disabled",
+			issuedAt:        now.Add(-55 * time.Minute),
+			expiresAt:       now.Add(5 * time.Minute),
+			expectedRenewed: false,
+		},
+		{
+			name:            "Outside renewal window",
+			renewal:         authentication.Renewal{Window: 10 * time.Minute},
+			issuedAt:        now.Add(-30 * time.Minute),
+			expiresAt:       now.Add(30 * time.Minute),
+			expectedRenewed: false,
+		},
+		{
+			name:            "Within renewal window",
+			renewal:         authentication.Renewal{Window: 10 * time.Minute},
+			issuedAt:        now.Add(-55 * time.Minute),
+			expiresAt:       now.Add(5 * time.Minute),
+			expectedRenewed: true,
+			expectedExpires: now.Add(time.Hour),
+		},
+		{
+			name:            "Capped by maximum lifetime",
+			renewal:         authentication.Renewal{Window: 10 * time.Minute, MaxLifetime: 90 * time.Minute},
+			issuedAt:        now.Add(-55 * time.Minute),
+			expiresAt:       now.Add(5 * time.Minute),
+			expectedRenewed: true,
+			expectedExpires: now.Add(35 * time.Minute),
+		},
+		{
+			name:            "Capped by expiration limit",
+			renewal:         authentication.Renewal{Window: 10 * time.Minute},
+			issuedAt:        now.Add(-55 * time.Minute),
+			expiresAt:       now.Add(5 * time.Minute),
+			expirationLimit: now.Add(20 * time.Minute),
+			expectedRenewed: true,
+			expectedExpires: now.Add(20 * time.Minute),
+		},
+		{
+			name:            "Expiration limit reached",
+			renewal:         authentication.Renewal{Window: 10 * time.Minute},
+			issuedAt:        now.Add(-55 * time.Minute),
+			expiresAt:       now.Add(5 * time.Minute),
+			expirationLimit: now.Add(5 * time.Minute),
+			expectedRenewed: false,
+		},
+		{
+			name:            "Maximum lifetime reached",
+			renewal:         authentication.Renewal{Window: 10 * time.Minute, MaxLifetime: time.Hour},
+			issuedAt:        now.Add(-55 * time.Minute),
+			expiresAt:       now.Add(5 * time.Minute),
+			expectedRenewed: false,
+		},
+	}
+
+	auth := authentication.Default("secret", 3600)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{
+				"id":       "session-id",
+				"sub":      "user-1",
+				"iat":      float64(tt.issuedAt.Unix()),
+				"exp":      float64(tt.expiresAt.Unix()),
+				"orig_iat": float64(tt.issuedAt.Unix()),
+			}
+
+			if !tt.expirationLimit.IsZero() {
+				claims["max_exp"] = float64(tt.expirationLimit.Unix())
+			}
+
+			renewed, ok := auth.RenewClaims(claims, tt.renewal)
+			require.Equal(t, tt.expectedRenewed, ok)
+
+			if !tt.expectedRenewed {
+				return
+			}
+
+			expirationTime, err := renewed.GetExpirationTime()
+			require.NoError(t, err)
+			assert.WithinDuration(t, tt.expectedExpires, expirationTime.Time, 2*time.Second)
+			assert.NotEqual(t, claims["id"], renewed["id"])
+			assert.Equal(t, float64(tt.issuedAt.Unix()), renewed["orig_iat"])
+			assert.Equal(t, "user-1", renewed["sub"])
+		})
+	}
+}
+
+func TestRenewal_WriteToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("Default header", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		authentication.Renewal{}.WriteToken(rr, "token", expiresAt)
+
+		assert.Equal(t, "token", rr.Header().Get(authentication.DefaultRenewalHeader))
+	})
+
+	t.Run("Cookie", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		authentication.Renewal{Cookie: "session"}.WriteToken(rr, "token", expiresAt)
+
+		assert.Empty(t, rr.Header().Get(authentication.DefaultRenewalHeader))
+
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "session", cookies[0].Name)
+		assert.Equal(t, "token", cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+}
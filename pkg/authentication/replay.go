@@ -0,0 +1,55 @@
+package authentication
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReplayStore records single use values, such as used one-time codes and consumed tokens.
+type ReplayStore interface {
+	// Use atomically records a value for the given duration, and reports whether it was unused.
+	// A zero duration records the value permanently.
+	Use(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryReplayStore is an in-memory ReplayStore, for single instance deployments and tests.
+type MemoryReplayStore struct {
+	mutex sync.Mutex
+	used  map[string]time.Time
+}
+
+// NewMemoryReplayStore is a MemoryReplayStore constructor.
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{
+		used: map[string]time.Time{},
+	}
+}
+
+// Use atomically records a value for the given duration, and reports whether it was unused.
+// A zero duration records the value permanently. Expired values are removed on use.
+func (s *MemoryReplayStore) Use(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	for usedKey, expiresAt := range s.used {
+		if !expiresAt.IsZero() && !now.Before(expiresAt) {
+			delete(s.used, usedKey)
+		}
+	}
+
+	if _, ok := s.used[key]; ok {
+		return false, nil
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	s.used[key] = expiresAt
+
+	return true, nil
+}
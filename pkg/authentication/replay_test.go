@@ -0,0 +1,43 @@
+package authentication_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestMemoryReplayStore(t *testing.T) {
+	ctx := context.Background()
+	store := authentication.NewMemoryReplayStore()
+
+	ok, err := store.Use(ctx, "code-1", time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.Use(ctx, "code-1", time.Millisecond)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Values can be used again once expired.
+	time.Sleep(2 * time.Millisecond)
+
+	ok, err = store.Use(ctx, "code-1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Values used without duration never expire.
+	ok, err = store.Use(ctx, "code-2", 0)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	time.Sleep(time.Millisecond)
+
+	ok, err = store.Use(ctx, "code-2", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,143 @@
+package authentication
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// AuthorizationFunc reports whether the request claims are authorized.
+type AuthorizationFunc func(r *http.Request, claims Claims) bool
+
+// Require wraps handlers so they are only served to the given roles, or the admin role.
+//
+// It reads the claims placed in context by the middleware, which must wrap the handlers first.
+func (m *Middleware) Require(roles ...string) func(http.Handler) http.Handler {
+	return m.RequireFunc(func(_ *http.Request, claims Claims) bool {
+		return slices.Contains(roles, claims.Role)
+	})
+}
+
+// RequireScopes wraps handlers so they are only served to tokens with every given scope, or the admin role.
+//
+// It reads the claims placed in context by the middleware, which must wrap the handlers first.
+func (m *Middleware) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return m.RequireFunc(func(_ *http.Request, claims Claims) bool {
+		tokenScopes := claims.Scopes()
+
+		for _, scope := range scopes {
+			if !slices.Contains(tokenScopes, scope) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// RequireFunc wraps handlers so they are only served to the claims authorized by the given function,
+// or the admin role.
+//
+// Requests without claims fail with ErrUnauthorized, while unauthorized claims fail with ErrForbidden.
+func (m *Middleware) RequireFunc(authorize AuthorizationFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return requirement{
+			authorize: authorize,
+			m:         m,
+			next:      next,
+		}
+	}
+}
+
+// requirement is a handler wrapped by RequireFunc.
+type requirement struct {
+	authorize AuthorizationFunc
+	m         *Middleware
+	next      http.Handler
+}
+
+func (h requirement) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.m.GetClaims(r.Context())
+	if err != nil {
+		h.m.errorRenderer(w, r, ErrUnauthorized)
+		return
+	}
+
+	// The admin role is the tenant one, when multi-tenancy is used.
+	s, err := h.m.contextScope(r.Context())
+	if err != nil {
+		h.m.errorRenderer(w, r, ErrUnauthorized)
+		return
+	}
+
+	if (s.adminRole == "" || claims.Role != s.adminRole) && !h.authorize(r, claims) {
+		h.m.errorRenderer(w, r, ErrForbidden)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// Unwrap returns the wrapped handler.
+func (h requirement) Unwrap() http.Handler {
+	return h.next
+}
+
+var routeWildcard = regexp.MustCompile(`\{[^}]*\}`)
+
+// UnprotectedRoutes returns the given ServeMux routes that aren't served by a Require wrapper,
+// so they can be reported at startup. Routes that aren't registered in the mux are also returned.
+//
+// Routes are written as ServeMux patterns, such as "GET /orders/{id}", as a ServeMux doesn't list its patterns.
+// A route is protected when its handler is a Require wrapper, or a handler with an Unwrap() http.Handler method
+// that leads to one.
+func UnprotectedRoutes(mux *http.ServeMux, routes ...string) []string {
+	var unprotected []string
+
+	for _, route := range routes {
+		method, path, found := strings.Cut(route, " ")
+		if !found {
+			method, path = http.MethodGet, route
+		}
+
+		host := ""
+		if !strings.HasPrefix(path, "/") {
+			host, path, _ = strings.Cut(path, "/")
+			path = "/" + path
+		}
+
+		path = strings.TrimSuffix(path, "{$}")
+		path = routeWildcard.ReplaceAllString(path, "_")
+
+		req, err := http.NewRequest(method, "http://"+host+path, nil)
+		if err != nil {
+			unprotected = append(unprotected, route)
+			continue
+		}
+
+		if handler, pattern := mux.Handler(req); pattern == "" || !isProtected(handler) {
+			unprotected = append(unprotected, route)
+		}
+	}
+
+	return unprotected
+}
+
+// isProtected reports whether a handler, or a handler it wraps, is a Require wrapper.
+func isProtected(handler http.Handler) bool {
+	for handler != nil {
+		if _, ok := handler.(requirement); ok {
+			return true
+		}
+
+		wrapper, ok := handler.(interface{ Unwrap() http.Handler })
+		if !ok {
+			return false
+		}
+
+		handler = wrapper.Unwrap()
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package authentication_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+func TestMiddleware_Require(t *testing.T) {
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600),
+		authentication.WithAdminRole("admin"),
+	)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		claims         *authentication.Claims
+		expectedStatus int
+	}{
+		{
+			name:           "Allowed role",
+			handler:        middleware.Require("user", "editor")(ok),
+			claims:         &authentication.Claims{Role: "editor"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Denied role",
+			handler:        middleware.Require("editor")(ok),
+			claims:         &authentication.Claims{Role: "user"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Admin role",
+			handler:        middleware.Require("editor")(ok),
+			claims:         &authentication.Claims{Role: "admin"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Without claims",
+			handler:        middleware.Require("user")(ok),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Allowed scopes",
+			handler:        middleware.RequireScopes("orders:read", "orders:write")(ok),
+			claims:         &authentication.Claims{Role: "user", Scope: "orders:write orders:read"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing scope",
+			handler:        middleware.RequireScopes("orders:read", "orders:write")(ok),
+			claims:         &authentication.Claims{Role: "user", Scope: "orders:read"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name: "Authorization function",
+			handler: middleware.RequireFunc(func(r *http.Request, claims authentication.Claims) bool {
+				return r.PathValue("id") == claims.Subject
+			})(ok),
+			claims:         &authentication.Claims{Role: "user", Subject: "user-1"},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/user-2", nil)
+			req.SetPathValue("id", "user-2")
+
+			if tt.claims != nil {
+				claims := *tt.claims
+				claims.IssuedAt = time.Now().Unix()
+				claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+
+				req = authtest.RequestWithClaims(req, authentication.ClaimsKey("secret"), claims)
+			}
+
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
+
+// logging is a handler wrapper that exposes the handler it wraps.
+type logging struct {
+	next http.Handler
+}
+
+func (l logging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.next.ServeHTTP(w, r)
+}
+
+func (l logging) Unwrap() http.Handler {
+	return l.next
+}
+
+func TestUnprotectedRoutes(t *testing.T) {
+	middleware := authentication.NewMiddleware(authentication.Default("secret", 3600))
+	handler := http.NotFoundHandler()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /orders/{id}", middleware.Require("user")(handler))
+	mux.Handle("POST /orders", logging{next: middleware.RequireScopes("orders:write")(handler)})
+	mux.Handle("GET /health", handler)
+	mux.Handle("api.example.com/reports/", handler)
+	mux.Handle("DELETE /orders/{id}", logging{next: handler})
+
+	unprotected := authentication.UnprotectedRoutes(mux,
+		"GET /orders/{id}",
+		"POST /orders",
+		"GET /health",
+		"api.example.com/reports/",
+		"DELETE /orders/{id}",
+		"PUT /missing",
+	)
+
+	require.Equal(t, []string{
+		"GET /health",
+		"api.example.com/reports/",
+		"DELETE /orders/{id}",
+		"PUT /missing",
+	}, unprotected)
+}
@@ -0,0 +1,81 @@
+package authentication
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// SessionStore keeps the server side sessions of issued tokens, so they can be revoked before they expire.
+//
+// Sessions are identified by the token "id" claim.
+type SessionStore interface {
+	// Save stores the session of a token, for the given duration.
+	Save(ctx context.Context, id, token string, ttl time.Duration) error
+	// Exists reports whether a session is still active.
+	Exists(ctx context.Context, id string) (bool, error)
+	// Delete removes a session, and reports whether it was active.
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// SessionTracker is implemented by session stores that keep track of the session activity.
+type SessionTracker interface {
+	// Touch records that a session of the subject was used.
+	Touch(ctx context.Context, subject, id string) error
+}
+
+// TokenDenylist is implemented by session stores that keep a denylist of revoked tokens.
+//
+// The middleware rejects denied tokens, so revoked tokens are rejected even by handlers that don't read their claims.
+type TokenDenylist interface {
+	// Deny removes the session of a token and adds the token to the denylist, for the given duration,
+	// which should be the remaining lifetime of the token.
+	Deny(ctx context.Context, id string, ttl time.Duration) error
+	// Denied reports whether a token is in the denylist.
+	Denied(ctx context.Context, id string) (bool, error)
+}
+
+// SessionMetadata describes the client of a token session.
+//
+// It reaches the session store through the Save context, so stores can keep it with the session.
+// RenewedFrom is the ID of the session replaced by a token renewal or refresh, whose metadata is kept.
+type SessionMetadata struct {
+	Subject     string    `json:"subject"`
+	IP          string    `json:"ip,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	Device      string    `json:"device,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	RenewedFrom string    `json:"-"`
+}
+
+type sessionMetadataKey struct{}
+
+// RequestSessionMetadata returns the client IP address and user agent of a request, with the given device label.
+//
+// The IP address is the request remote address, so proxies must set it from trusted forwarding headers.
+func RequestSessionMetadata(r *http.Request, device string) SessionMetadata {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return SessionMetadata{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+		Device:    device,
+	}
+}
+
+// ContextWithSessionMetadata stores the session metadata in context, to be used by Login.
+func ContextWithSessionMetadata(ctx context.Context, metadata SessionMetadata) context.Context {
+	return context.WithValue(ctx, sessionMetadataKey{}, metadata)
+}
+
+// SessionMetadataFromContext extracts the session metadata stored in context.
+func SessionMetadataFromContext(ctx context.Context) (SessionMetadata, bool) {
+	metadata, ok := ctx.Value(sessionMetadataKey{}).(SessionMetadata)
+
+	return metadata, ok
+}
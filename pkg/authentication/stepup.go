@@ -0,0 +1,192 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Authentication method references of the "amr" claim, as registered by RFC 8176.
+const (
+	MethodPassword = "pwd"
+	MethodOTP      = "otp"
+	MethodMFA      = "mfa"
+)
+
+// ReasonInsufficientUserAuthentication denies a token whose authentication doesn't meet a step-up rule.
+const ReasonInsufficientUserAuthentication = "insufficient user authentication"
+
+// UserAuthentication describes how the user authenticated, as the "acr", "amr" and "auth_time" claims.
+//
+// ACR is the authentication context class reference.
+// AMR is the list of authentication method references, such as MethodPassword and MethodOTP.
+// Time is when the user authenticated, which is kept across token renewals and refreshes.
+type UserAuthentication struct {
+	ACR  string
+	AMR  []string
+	Time time.Time
+}
+
+type userAuthenticationKey struct{}
+
+// ContextWithUserAuthentication stores the user authentication in context, to be used by Login.
+func ContextWithUserAuthentication(ctx context.Context, userAuth UserAuthentication) context.Context {
+	return context.WithValue(ctx, userAuthenticationKey{}, userAuth)
+}
+
+// UserAuthenticationFromContext extracts the user authentication stored in context.
+func UserAuthenticationFromContext(ctx context.Context) (UserAuthentication, bool) {
+	userAuth, ok := ctx.Value(userAuthenticationKey{}).(UserAuthentication)
+
+	return userAuth, ok
+}
+
+// SetClaims sets the user authentication claims. Empty values aren't set.
+func (u UserAuthentication) SetClaims(claims jwt.MapClaims) {
+	if u.ACR != "" {
+		claims["acr"] = u.ACR
+	}
+
+	if len(u.AMR) > 0 {
+		claims["amr"] = slices.Clone(u.AMR)
+	}
+
+	if !u.Time.IsZero() {
+		claims["auth_time"] = u.Time.Unix()
+	}
+}
+
+// userAuthentication reads the user authentication claims.
+// The "amr" claim is read as a list of strings, or as a single string.
+func userAuthentication(claims jwt.MapClaims) UserAuthentication {
+	var userAuth UserAuthentication
+
+	userAuth.ACR, _ = claims["acr"].(string)
+
+	switch amr := claims["amr"].(type) {
+	case string:
+		userAuth.AMR = []string{amr}
+	case []string:
+		userAuth.AMR = slices.Clone(amr)
+	case []any:
+		for _, method := range amr {
+			if value, ok := method.(string); ok {
+				userAuth.AMR = append(userAuth.AMR, value)
+			}
+		}
+	}
+
+	if authTime, ok := numericClaim(claims, "auth_time"); ok {
+		userAuth.Time = authTime
+	}
+
+	return userAuth
+}
+
+// StepUpRule requires a stronger or recent user authentication to access endpoints, by path prefix.
+//
+// ACR is the list of accepted authentication context classes, from which any is enough.
+// AMR is the list of required authentication methods, which must all have been used.
+// MaxAge is the maximum time since the user authenticated.
+// Empty values aren't required.
+type StepUpRule struct {
+	Path   string
+	ACR    []string
+	AMR    []string
+	MaxAge time.Duration
+}
+
+// Satisfied reports whether the user authentication meets the rule, at the given time.
+func (s StepUpRule) Satisfied(userAuth UserAuthentication, now time.Time) bool {
+	if len(s.ACR) > 0 && !slices.Contains(s.ACR, userAuth.ACR) {
+		return false
+	}
+
+	for _, method := range s.AMR {
+		if !slices.Contains(userAuth.AMR, method) {
+			return false
+		}
+	}
+
+	if s.MaxAge > 0 && (userAuth.Time.IsZero() || now.Sub(userAuth.Time) > s.MaxAge) {
+		return false
+	}
+
+	return true
+}
+
+// StepUpError is returned when a token doesn't meet a step-up rule, so the client can run a step-up flow.
+//
+// It matches ErrInsufficientUserAuthentication with errors.Is.
+type StepUpError struct {
+	Rule StepUpRule
+}
+
+func (e *StepUpError) Error() string {
+	return ErrInsufficientUserAuthentication.Error()
+}
+
+// Is reports whether the target is ErrInsufficientUserAuthentication.
+func (e *StepUpError) Is(target error) bool {
+	return target == ErrInsufficientUserAuthentication
+}
+
+// Challenge returns the WWW-Authenticate header value of the error, as defined by RFC 9470.
+func (e *StepUpError) Challenge() string {
+	challenge := fmt.Sprintf(`Bearer error=%q, error_description="A different authentication level is required"`,
+		ErrInsufficientUserAuthentication.Error())
+
+	if len(e.Rule.ACR) > 0 {
+		challenge += fmt.Sprintf(", acr_values=%q", strings.Join(e.Rule.ACR, " "))
+	}
+
+	if e.Rule.MaxAge > 0 {
+		challenge += ", max_age=" + strconv.FormatInt(int64(e.Rule.MaxAge.Seconds()), 10)
+	}
+
+	return challenge
+}
+
+// newStepUpTree builds the prefix tree of the step-up rules, by path. Rules of the same path are kept in order.
+func newStepUpTree(rules []StepUpRule) *prefixTree[[]StepUpRule] {
+	paths := map[string][]StepUpRule{}
+
+	for _, rule := range rules {
+		paths[rule.Path] = append(paths[rule.Path], rule)
+	}
+
+	return newPrefixTree(paths)
+}
+
+// checkStepUp returns the error of the first step-up rule, matching the request path, that isn't met.
+// Every matching rule applies, from the shortest path to the longest one, so rules of parent paths can't be relaxed.
+func (m *Middleware) checkStepUp(r *http.Request, claims jwt.MapClaims) *StepUpError {
+	if m.stepUpTree == nil {
+		return nil
+	}
+
+	var (
+		stepUpErr *StepUpError
+		userAuth  = userAuthentication(claims)
+		now       = time.Now()
+	)
+
+	m.stepUpTree.each(r.URL.Path, func(_ string, rules []StepUpRule) bool {
+		for _, rule := range rules {
+			if !rule.Satisfied(userAuth, now) {
+				stepUpErr = &StepUpError{Rule: rule}
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return stepUpErr
+}
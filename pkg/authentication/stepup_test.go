@@ -0,0 +1,164 @@
+package authentication_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+func TestStepUpRule_Satisfied(t *testing.T) {
+	now := time.Now()
+
+	rule := authentication.StepUpRule{
+		Path:   "/payouts",
+		ACR:    []string{"urn:example:mfa", "urn:example:hardware"},
+		AMR:    []string{authentication.MethodOTP},
+		MaxAge: 5 * time.Minute,
+	}
+
+	tests := []struct {
+		name           string
+		userAuth       authentication.UserAuthentication
+		expectedResult bool
+	}{
+		{
+			name: "Recent MFA login",
+			userAuth: authentication.UserAuthentication{
+				ACR:  "urn:example:mfa",
+				AMR:  []string{authentication.MethodPassword, authentication.MethodOTP},
+				Time: now.Add(-time.Minute),
+			},
+			expectedResult: true,
+		},
+		{
+			name: "Old MFA login",
+			userAuth: authentication.UserAuthentication{
+				ACR:  "urn:example:mfa",
+				AMR:  []string{authentication.MethodPassword, authentication.MethodOTP},
+				Time: now.Add(-time.Hour),
+			},
+			expectedResult: false,
+		},
+		{
+			name: "Missing method",
+			userAuth: authentication.UserAuthentication{
+				ACR:  "urn:example:mfa",
+				AMR:  []string{authentication.MethodPassword},
+				Time: now,
+			},
+			expectedResult: false,
+		},
+		{
+			name: "Unaccepted context class",
+			userAuth: authentication.UserAuthentication{
+				ACR:  "urn:example:password",
+				AMR:  []string{authentication.MethodOTP},
+				Time: now,
+			},
+			expectedResult: false,
+		},
+		{
+			name: "Missing authentication time",
+			userAuth: authentication.UserAuthentication{
+				ACR: "urn:example:hardware",
+				AMR: []string{authentication.MethodOTP},
+			},
+			expectedResult: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedResult, rule.Satisfied(tt.userAuth, now))
+		})
+	}
+}
+
+func TestMiddleware_StepUp(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	middleware := authentication.NewMiddleware(
+		auth,
+		authentication.WithStepUp(authentication.StepUpRule{
+			Path:   "/payouts",
+			AMR:    []string{authentication.MethodOTP},
+			MaxAge: 5 * time.Minute,
+		}),
+	)
+
+	passwordToken, err := middleware.Login(context.Background(), "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	ctx := authentication.ContextWithUserAuthentication(context.Background(), authentication.UserAuthentication{
+		AMR:  []string{authentication.MethodPassword, authentication.MethodOTP},
+		Time: time.Now(),
+	})
+
+	otpToken, err := middleware.Login(ctx, "user-1", "issuer", "audience", "user")
+	require.NoError(t, err)
+
+	t.Run("Route without rules", func(t *testing.T) {
+		rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodGet, "/user", nil), passwordToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, err)
+		assert.Zero(t, claims.AuthTime)
+	})
+
+	t.Run("Issued without user authentication", func(t *testing.T) {
+		recentOnly := authentication.NewMiddleware(auth, authentication.WithStepUp(authentication.StepUpRule{
+			Path:   "/payouts",
+			MaxAge: 5 * time.Minute,
+		}))
+
+		rr, _, _ := serve(t, recentOnly, httptest.NewRequest(http.MethodPost, "/payouts", nil), passwordToken)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		rr, _, _ = serve(t, recentOnly, httptest.NewRequest(http.MethodPost, "/payouts", nil), otpToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Parent path rules", func(t *testing.T) {
+		nested := authentication.NewMiddleware(auth, authentication.WithStepUp(
+			authentication.StepUpRule{Path: "/payouts", AMR: []string{authentication.MethodOTP}},
+			authentication.StepUpRule{Path: "/payouts/history", MaxAge: time.Hour},
+		))
+
+		rr, _, _ := serve(t, nested, httptest.NewRequest(http.MethodGet, "/payouts/history", nil), passwordToken)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		rr, _, _ = serve(t, nested, httptest.NewRequest(http.MethodGet, "/payouts/history", nil), otpToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Insufficient authentication", func(t *testing.T) {
+		rr, _, _ := serve(t, middleware, httptest.NewRequest(http.MethodPost, "/payouts", nil), passwordToken)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.JSONEq(t, `{"message":"insufficient_user_authentication"}`, rr.Body.String())
+		assert.Equal(t,
+			`Bearer error="insufficient_user_authentication", `+
+				`error_description="A different authentication level is required", max_age=300`,
+			rr.Header().Get("WWW-Authenticate"),
+		)
+	})
+
+	t.Run("Step-up authentication", func(t *testing.T) {
+		rr, claims, err := serve(t, middleware, httptest.NewRequest(http.MethodPost, "/payouts", nil), otpToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, err)
+		assert.Equal(t, []string{authentication.MethodPassword, authentication.MethodOTP}, claims.AMR)
+	})
+}
+
+func TestStepUpError(t *testing.T) {
+	var err error = &authentication.StepUpError{Rule: authentication.StepUpRule{ACR: []string{"urn:example:mfa"}}}
+
+	assert.ErrorIs(t, err, authentication.ErrInsufficientUserAuthentication)
+	assert.Equal(t, "insufficient_user_authentication", err.Error())
+}
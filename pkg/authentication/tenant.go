@@ -0,0 +1,281 @@
+package authentication
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TenantClaim is the claim that binds a token to a tenant.
+const TenantClaim = "tenant"
+
+// Tenant holds the authentication settings of one tenant.
+//
+// Issuer is the required "iss" claim of the tenant tokens. Empty skips the issuer check.
+// SigningMethod, SigningKey and VerificationKey replace the Auth ones for the tenant tokens.
+// AdminRole and PermissionsMap replace the middleware ones for the tenant requests.
+// PermissionsMap is compiled when the tenant is resolved, and a TenantCache keeps it compiled with the tenant.
+type Tenant struct {
+	ID              string
+	Issuer          string
+	SigningMethod   jwt.SigningMethod
+	SigningKey      any
+	VerificationKey any
+	AdminRole       string
+	PermissionsMap  map[string][]string
+
+	keyID       string
+	permissions *prefixTree[[]string]
+}
+
+// compile returns the tenant with its permissions map compiled, and its verification key identified, unless it
+// already is.
+func (t Tenant) compile() Tenant {
+	if t.permissions == nil {
+		t.permissions = newPrefixTree(t.PermissionsMap)
+	}
+
+	if t.keyID == "" && (t.SigningKey != nil || t.VerificationKey != nil) {
+		t.keyID = t.auth().keyID
+	}
+
+	return t
+}
+
+// auth returns an Auth holding the tenant keys, with its verification key identified.
+func (t Tenant) auth() Auth {
+	auth := Auth{SigningKey: t.SigningKey, VerificationKey: t.VerificationKey}
+	auth.keyID = verificationKeyID(auth.verificationKey())
+
+	return auth
+}
+
+// TenantResolver resolves the authentication settings of a tenant.
+//
+// Implementations should return ErrTenantNotFound for unknown tenants.
+type TenantResolver interface {
+	Resolve(ctx context.Context, tenantID string) (Tenant, error)
+}
+
+// TenantExtractor extracts the tenant ID of a request. It returns an empty string when there's none.
+type TenantExtractor func(r *http.Request, tokenString string) string
+
+// TenantFromClaim extracts the tenant ID from a token claim.
+//
+// The token isn't verified at this point, since its tenant defines the keys used to verify it.
+// Encrypted tokens don't expose their claims, so the header or host extractors should be used with them.
+func TenantFromClaim(claim string) TenantExtractor {
+	return func(_ *http.Request, tokenString string) string {
+		claims := jwt.MapClaims{}
+
+		_, _, err := jwt.NewParser().ParseUnverified(tokenString, claims)
+		if err != nil {
+			return ""
+		}
+
+		tenantID, _ := claims[claim].(string)
+
+		return tenantID
+	}
+}
+
+// TenantFromHeader extracts the tenant ID from a request header.
+func TenantFromHeader(header string) TenantExtractor {
+	return func(r *http.Request, _ string) string {
+		return r.Header.Get(header)
+	}
+}
+
+// TenantFromHost extracts the tenant ID from the request host, without port.
+func TenantFromHost() TenantExtractor {
+	return func(r *http.Request, _ string) string {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		return strings.ToLower(host)
+	}
+}
+
+// ResolveTenant resolves the tenant of a request. The tenant claim is used when no extractor is given.
+func ResolveTenant(r *http.Request, tokenString string, extractor TenantExtractor, resolver TenantResolver) (Tenant, error) {
+	if extractor == nil {
+		extractor = TenantFromClaim(TenantClaim)
+	}
+
+	tenantID := extractor(r, tokenString)
+	if tenantID == "" {
+		return Tenant{}, ErrTenantNotFound
+	}
+
+	return resolveTenant(r.Context(), tenantID, resolver)
+}
+
+// resolveTenant resolves a tenant by ID, with its permissions compiled.
+func resolveTenant(ctx context.Context, tenantID string, resolver TenantResolver) (Tenant, error) {
+	tenant, err := resolver.Resolve(ctx, tenantID)
+	if err != nil {
+		return Tenant{}, err
+	}
+
+	// The resolved tenant must be the requested one, so the tenant claim check can't be bypassed.
+	if tenant.ID != tenantID {
+		return Tenant{}, ErrTenantMismatch
+	}
+
+	return tenant.compile(), nil
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant stores the tenant ID in context, so the tokens issued with it are signed for the tenant.
+//
+// The middleware stores the tenant of authenticated requests, so it only has to be set where tokens are issued
+// outside of them, such as on login.
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext extracts the tenant ID stored in context.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+
+	return tenantID, ok && tenantID != ""
+}
+
+// TenantContext returns the request context with the request tenant, extracted with the tenant extractor,
+// when multi-tenancy is used. A tenant already stored in context is kept.
+//
+// It fails with ErrTenantNotFound when the request has no tenant, such as a login request when tenants are
+// extracted from the token claim.
+func (m *Middleware) TenantContext(r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+
+	if _, ok := TenantFromContext(ctx); ok || m.tenants == nil {
+		return ctx, nil
+	}
+
+	tenant, err := ResolveTenant(r, m.extractor(r), m.tenantExtractor, m.tenants)
+	if err != nil {
+		return ctx, err
+	}
+
+	return ContextWithTenant(ctx, tenant.ID), nil
+}
+
+// contextScope returns the settings of the tenant stored in context, used to issue tokens and authorize handlers,
+// or the global ones when multi-tenancy isn't used.
+func (m *Middleware) contextScope(ctx context.Context) (scope, error) {
+	if m.tenants == nil {
+		policy := m.policy.Load()
+
+		return scope{
+			adminRole:   policy.AdminRole,
+			auth:        m.auth,
+			permissions: policy.permissions,
+		}, nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return scope{}, ErrTenantNotFound
+	}
+
+	tenant, err := resolveTenant(ctx, tenantID, m.tenants)
+	if err != nil {
+		return scope{}, err
+	}
+
+	return m.tenantScope(tenant), nil
+}
+
+// ForTenant returns a copy of the Auth that signs and verifies tokens of the given tenant.
+//
+// Tokens are bound to the tenant through the tenant claim, so a token issued for one tenant is
+// never valid for another, even if both share the same keys.
+func (a *Auth) ForTenant(tenant Tenant) Auth {
+	auth := *a
+	auth.tenant = &tenant
+
+	if tenant.SigningMethod != nil {
+		auth.SigningMethod = tenant.SigningMethod
+		auth.VerificationMethods = nil
+	}
+
+	if tenant.SigningKey != nil || tenant.VerificationKey != nil {
+		auth.SigningKey = tenant.SigningKey
+		auth.VerificationKey = tenant.VerificationKey
+		auth.keyID = tenant.keyID
+
+		if auth.keyID == "" {
+			auth.keyID = tenant.auth().keyID
+		}
+	}
+
+	return auth
+}
+
+type cachedTenant struct {
+	tenant    Tenant
+	expiresAt time.Time
+}
+
+// TenantCache is a TenantResolver that caches the tenants of another resolver.
+type TenantCache struct {
+	mutex    sync.RWMutex
+	resolver TenantResolver
+	tenants  map[string]cachedTenant
+	ttl      time.Duration
+}
+
+// NewTenantCache is a TenantCache constructor.
+//
+// resolver is the resolver whose tenants are cached.
+// ttl is the duration of a cached tenant.
+func NewTenantCache(resolver TenantResolver, ttl time.Duration) *TenantCache {
+	return &TenantCache{
+		resolver: resolver,
+		tenants:  map[string]cachedTenant{},
+		ttl:      ttl,
+	}
+}
+
+// Resolve returns the cached tenant, or resolves and caches it, with its permissions compiled.
+func (c *TenantCache) Resolve(ctx context.Context, tenantID string) (Tenant, error) {
+	c.mutex.RLock()
+	cached, ok := c.tenants[tenantID]
+	c.mutex.RUnlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.tenant, nil
+	}
+
+	tenant, err := c.resolver.Resolve(ctx, tenantID)
+	if err != nil {
+		return Tenant{}, err
+	}
+
+	tenant = tenant.compile()
+
+	c.mutex.Lock()
+	c.tenants[tenantID] = cachedTenant{
+		tenant:    tenant,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	c.mutex.Unlock()
+
+	return tenant, nil
+}
+
+// Invalidate removes a tenant from cache, so its settings are resolved again.
+func (c *TenantCache) Invalidate(tenantID string) {
+	c.mutex.Lock()
+	delete(c.tenants, tenantID)
+	c.mutex.Unlock()
+}
@@ -0,0 +1,315 @@
+package authentication_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication/authtest"
+)
+
+type mockTenantResolver struct {
+	calls   int
+	tenants map[string]authentication.Tenant
+}
+
+func (m *mockTenantResolver) Resolve(_ context.Context, tenantID string) (authentication.Tenant, error) {
+	m.calls++
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return authentication.Tenant{}, authentication.ErrTenantNotFound
+	}
+
+	return tenant, nil
+}
+
+func newMockTenantResolver() *mockTenantResolver {
+	return &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", Issuer: "issuer-a", SigningKey: []byte("secret-a")},
+			"tenant-b": {ID: "tenant-b", Issuer: "issuer-b", SigningKey: []byte("secret-b")},
+			"tenant-c": {ID: "tenant-c", Issuer: "issuer-c", SigningKey: []byte("secret-a")},
+		},
+	}
+}
+
+func TestAuth_ForTenant(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+	resolver := newMockTenantResolver()
+
+	authA := auth.ForTenant(resolver.tenants["tenant-a"])
+
+	tokenString, err := authA.ClaimsToken(authentication.NewMapClaims("user-1", "issuer-a", "aud", "user", time.Hour))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		tenant        string
+		expectedError bool
+	}{
+		{
+			name:   "Same tenant",
+			tenant: "tenant-a",
+		},
+		{
+			name:          "Different tenant keys",
+			tenant:        "tenant-b",
+			expectedError: true,
+		},
+		{
+			name:          "Different tenant with shared keys",
+			tenant:        "tenant-c",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantAuth := auth.ForTenant(resolver.tenants[tt.tenant])
+
+			claims, err := tenantAuth.ParseToken(tokenString)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "tenant-a", (*claims)[authentication.TenantClaim])
+		})
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	resolver := newMockTenantResolver()
+
+	tests := []struct {
+		name           string
+		extractor      authentication.TenantExtractor
+		host           string
+		header         string
+		expectedTenant string
+		expectedError  error
+	}{
+		{
+			name:           "From header",
+			extractor:      authentication.TenantFromHeader("X-Tenant"),
+			header:         "tenant-b",
+			expectedTenant: "tenant-b",
+		},
+		{
+			name:           "From host",
+			extractor:      authentication.TenantFromHost(),
+			host:           "tenant-a:8080",
+			expectedTenant: "tenant-a",
+		},
+		{
+			name:          "Missing tenant",
+			extractor:     authentication.TenantFromHeader("X-Tenant"),
+			expectedError: authentication.ErrTenantNotFound,
+		},
+		{
+			name:          "Unknown tenant",
+			extractor:     authentication.TenantFromHeader("X-Tenant"),
+			header:        "tenant-z",
+			expectedError: authentication.ErrTenantNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = tt.host
+			req.Header.Set("X-Tenant", tt.header)
+
+			tenant, err := authentication.ResolveTenant(req, "", tt.extractor, resolver)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedTenant, tenant.ID)
+		})
+	}
+}
+
+func TestTenantCache(t *testing.T) {
+	resolver := newMockTenantResolver()
+	cache := authentication.NewTenantCache(resolver, time.Minute)
+
+	for range 3 {
+		tenant, err := cache.Resolve(context.Background(), "tenant-a")
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a", tenant.ID)
+	}
+
+	assert.Equal(t, 1, resolver.calls)
+
+	cache.Invalidate("tenant-a")
+
+	_, err := cache.Resolve(context.Background(), "tenant-a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, resolver.calls)
+}
+
+func TestMiddleware_TenantIssuance(t *testing.T) {
+	auth := authentication.Default("secret", 3600)
+
+	tenants := &mockTenantResolver{
+		tenants: map[string]authentication.Tenant{
+			"tenant-a": {ID: "tenant-a", SigningKey: []byte("secret-a"), AdminRole: "owner"},
+			"tenant-b": {ID: "tenant-b", SigningKey: []byte("secret-b")},
+		},
+	}
+
+	middleware := authentication.NewMiddleware(auth,
+		authentication.WithAdminRole("admin"),
+		authentication.WithSessionStore(authtest.NewSessionStore()),
+		authentication.WithTenants(tenants, authentication.TenantFromHeader("X-Tenant")),
+		authentication.WithImpersonation(authentication.ImpersonationPolicy{
+			Impersonators: []string{"owner"},
+			Roles:         []string{"user"},
+			Identities:    resolveIdentity,
+		}, &impersonationLog{}),
+		authentication.WithTokenExchange(authentication.ExchangePolicy{
+			Clients: map[string]authentication.ExchangeClient{
+				"service-a": {Audiences: []string{"audience"}, Role: "service"},
+			},
+		}),
+	)
+
+	mux := http.NewServeMux()
+	authentication.NewHandler(middleware, mockAuthenticator{}, "issuer", "audience").Register(mux)
+
+	post := func(path, tenant, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-Tenant", tenant)
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	accessToken := func(rr *httptest.ResponseRecorder) string {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var response authentication.TokenResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+		return response.AccessToken
+	}
+
+	authorize := func(tenant, token string) (authentication.Claims, error) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("X-Tenant", tenant)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		ctx, err := middleware.Authorize(nil, req)
+		if err != nil {
+			return authentication.Claims{}, err
+		}
+
+		return middleware.GetClaims(ctx)
+	}
+
+	credentials := `{"username":"john","password":"secret"}`
+
+	// Login tokens are issued for the request tenant, and don't validate on another one.
+	loginToken := accessToken(post("/token", "tenant-a", "", credentials))
+
+	claims, err := authorize("tenant-a", loginToken)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", claims.Tenant)
+
+	_, err = authorize("tenant-b", loginToken)
+	assert.ErrorIs(t, err, authentication.ErrUnauthorized)
+
+	assert.Equal(t, http.StatusBadRequest, post("/token", "", "", credentials).Code)
+
+	t.Run("Refresh", func(t *testing.T) {
+		refreshed := accessToken(post("/token/refresh", "tenant-a", loginToken, ""))
+
+		claims, err := authorize("tenant-a", refreshed)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a", claims.Tenant)
+	})
+
+	t.Run("Exchange", func(t *testing.T) {
+		tenantCtx := authentication.ContextWithTenant(context.Background(), "tenant-b")
+
+		userToken, err := middleware.Login(tenantCtx, "user-1", "issuer", "audience", "user")
+		require.NoError(t, err)
+
+		serviceToken, err := middleware.Login(tenantCtx, "service-a", "issuer", "audience", "service")
+		require.NoError(t, err)
+
+		exchanged, _, err := middleware.Exchange(context.Background(), authentication.ExchangeRequest{
+			SubjectToken: userToken,
+			ActorToken:   serviceToken,
+			Audience:     "audience",
+			Scope:        []string{"orders:read"},
+		})
+		require.NoError(t, err)
+
+		claims, err := authorize("tenant-b", exchanged)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-b", claims.Tenant)
+	})
+
+	t.Run("Impersonate", func(t *testing.T) {
+		ownerClaims := authentication.Claims{Subject: "owner-1", Issuer: "issuer", Role: "owner", Tenant: "tenant-a"}
+
+		impersonation, err := middleware.Impersonate(context.Background(), ownerClaims, "user-1", "ticket 42")
+		require.NoError(t, err)
+
+		claims, err := authorize("tenant-a", impersonation)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a", claims.Tenant)
+		assert.Equal(t, "ticket 42", claims.Impersonation)
+	})
+
+	t.Run("Require tenant admin role", func(t *testing.T) {
+		ctx := authentication.ContextWithTenant(context.Background(), "tenant-a")
+
+		ownerToken, err := middleware.Login(ctx, "owner-1", "issuer", "audience", "owner")
+		require.NoError(t, err)
+
+		userToken, err := middleware.Login(ctx, "user-1", "issuer", "audience", "user")
+		require.NoError(t, err)
+
+		handler := middleware.Middleware(middleware.Require("manager")(http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+		)))
+
+		for token, expectedStatus := range map[string]int{ownerToken: http.StatusOK, userToken: http.StatusForbidden} {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("X-Tenant", "tenant-a")
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, expectedStatus, rr.Code)
+		}
+	})
+}
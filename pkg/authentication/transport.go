@@ -0,0 +1,295 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultRefreshBefore is how long before its expiration a service token is refreshed, when no duration is set.
+const DefaultRefreshBefore = 30 * time.Second
+
+// ServiceToken is a token issued to a service.
+//
+// ExpiresAt is zero when the expiration time is unknown, such as for encrypted tokens,
+// in which case the token is used until it is rejected.
+type ServiceToken struct {
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+// TokenSource issues service tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (ServiceToken, error)
+}
+
+// TokenSourceFunc is a function that implements TokenSource.
+type TokenSourceFunc func(ctx context.Context) (ServiceToken, error)
+
+// Token calls the function.
+func (f TokenSourceFunc) Token(ctx context.Context) (ServiceToken, error) {
+	return f(ctx)
+}
+
+// LoginTokenSource returns a TokenSource that issues tokens locally, with the Login of the JWT middleware.
+func LoginTokenSource(jwtMiddleware JWT, subject, issuer, audience, role string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (ServiceToken, error) {
+		token, err := jwtMiddleware.Login(ctx, subject, issuer, audience, role)
+		if err != nil {
+			return ServiceToken{}, err
+		}
+
+		return ServiceToken{
+			AccessToken: token,
+			ExpiresAt:   expiresAt(token),
+		}, nil
+	})
+}
+
+// EndpointTokenSource returns a TokenSource that requests tokens from a token endpoint, such as the Handler one,
+// with the service credentials.
+//
+// client defaults to http.DefaultClient when nil.
+func EndpointTokenSource(client *http.Client, url, username, password string) TokenSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return TokenSourceFunc(func(ctx context.Context) (ServiceToken, error) {
+		body, err := json.Marshal(TokenRequest{
+			Username: username,
+			Password: password,
+		})
+		if err != nil {
+			return ServiceToken{}, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return ServiceToken{}, err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return ServiceToken{}, fmt.Errorf("token request failed: %v", err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return ServiceToken{}, fmt.Errorf("token request failed: status %d", resp.StatusCode)
+		}
+
+		var response TokenResponse
+
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil || response.AccessToken == "" {
+			return ServiceToken{}, fmt.Errorf("invalid token response: %v", err)
+		}
+
+		token := ServiceToken{
+			AccessToken: response.AccessToken,
+			ExpiresAt:   expiresAt(response.AccessToken),
+		}
+
+		if token.ExpiresAt.IsZero() && response.ExpiresIn > 0 {
+			token.ExpiresAt = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+		}
+
+		return token, nil
+	})
+}
+
+// Transport is an http.RoundTripper that sends service tokens as Bearer tokens.
+//
+// Tokens are cached and refreshed before they expire, and concurrent refreshes share the same token request.
+// When a request is rejected with an unauthorized status, the token is refreshed and the request is retried once,
+// if its body can be sent again.
+type Transport struct {
+	// Base is the underlying transport. It defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// RefreshBefore is how long before its expiration a token is refreshed. It defaults to DefaultRefreshBefore.
+	RefreshBefore time.Duration
+	// Source issues the service tokens.
+	Source TokenSource
+
+	mu      sync.Mutex
+	current ServiceToken
+	pending *tokenCall
+}
+
+// tokenCall is a token refresh, shared by the requests that wait for it.
+type tokenCall struct {
+	done  chan struct{}
+	token ServiceToken
+	err   error
+}
+
+// NewTransport is a Transport constructor.
+//
+// base is the underlying transport, which defaults to http.DefaultTransport when nil.
+func NewTransport(source TokenSource, base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:   base,
+		Source: source,
+	}
+}
+
+// RoundTrip sends the request with the current service token.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token(req.Context(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base().RoundTrip(withBearer(req, token.AccessToken))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The first response is returned when the request can't be sent again, or the token can't be refreshed.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	token, refreshErr := t.token(req.Context(), token.AccessToken)
+	if refreshErr != nil {
+		return resp, nil
+	}
+
+	retry := withBearer(req, token.AccessToken)
+
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+
+		retry.Body = body
+	}
+
+	resp.Body.Close()
+
+	return t.base().RoundTrip(retry)
+}
+
+// token returns the cached token, or refreshes it when it is about to expire or it was rejected.
+//
+// rejected is the token that was rejected, which is refreshed unless it was already replaced.
+func (t *Transport) token(ctx context.Context, rejected string) (ServiceToken, error) {
+	t.mu.Lock()
+
+	if t.valid(t.current, rejected, t.refreshBefore()) {
+		token := t.current
+		t.mu.Unlock()
+
+		return token, nil
+	}
+
+	call := t.pending
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		t.pending = call
+
+		// The refresh is shared, so it isn't canceled with the request that started it.
+		go t.refresh(context.WithoutCancel(ctx), call)
+	}
+
+	t.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return ServiceToken{}, ctx.Err()
+	}
+
+	if call.err != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		// A token that is still valid is used until it can be refreshed.
+		if t.valid(t.current, rejected, 0) {
+			return t.current, nil
+		}
+
+		return ServiceToken{}, fmt.Errorf("service token refresh failed: %v", call.err)
+	}
+
+	return call.token, nil
+}
+
+func (t *Transport) refresh(ctx context.Context, call *tokenCall) {
+	token, err := t.Source.Token(ctx)
+
+	t.mu.Lock()
+
+	if err == nil {
+		t.current = token
+	}
+
+	t.pending = nil
+	call.token, call.err = token, err
+
+	t.mu.Unlock()
+
+	close(call.done)
+}
+
+// valid reports whether a token can be used, for at least the given duration.
+func (t *Transport) valid(token ServiceToken, rejected string, duration time.Duration) bool {
+	if token.AccessToken == "" || token.AccessToken == rejected {
+		return false
+	}
+
+	return token.ExpiresAt.IsZero() || time.Until(token.ExpiresAt) > duration
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+func (t *Transport) refreshBefore() time.Duration {
+	if t.RefreshBefore <= 0 {
+		return DefaultRefreshBefore
+	}
+
+	return t.RefreshBefore
+}
+
+// withBearer returns a copy of the request with the given Bearer token, as a RoundTripper must not modify requests.
+func withBearer(req *http.Request, token string) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", tokenType+" "+token)
+
+	return clone
+}
+
+// expiresAt returns the expiration time of a token issued by this package.
+// Encrypted tokens can't be read without their key, so the zero time is returned for them.
+func expiresAt(token string) time.Time {
+	claims := jwt.MapClaims{}
+
+	_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	if err != nil {
+		return time.Time{}
+	}
+
+	expirationTime, err := claims.GetExpirationTime()
+	if err != nil || expirationTime == nil {
+		return time.Time{}
+	}
+
+	return expirationTime.Time
+}
@@ -0,0 +1,199 @@
+package authentication_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ribeirohugo/go_middlewares/pkg/authentication"
+)
+
+// countingSource issues numbered tokens, which expire after the given duration.
+type countingSource struct {
+	calls    atomic.Int32
+	delay    time.Duration
+	duration time.Duration
+}
+
+func (s *countingSource) Token(_ context.Context) (authentication.ServiceToken, error) {
+	call := s.calls.Add(1)
+
+	time.Sleep(s.delay)
+
+	return authentication.ServiceToken{
+		AccessToken: "token-" + strconv.Itoa(int(call)),
+		ExpiresAt:   time.Now().Add(s.duration),
+	}, nil
+}
+
+// bearerServer responds with the received Bearer token, and rejects the tokens in the rejected list.
+func bearerServer(t *testing.T, rejected ...string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		for _, value := range rejected {
+			if token == value {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		_, _ = w.Write([]byte(token + " " + string(body)))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestTransport_Cache(t *testing.T) {
+	server := bearerServer(t)
+	source := &countingSource{duration: time.Hour}
+	client := &http.Client{Transport: authentication.NewTransport(source, nil)}
+
+	for range 3 {
+		status, body := get(t, client, server.URL)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "token-1 ", body)
+	}
+
+	assert.Equal(t, int32(1), source.calls.Load())
+}
+
+func TestTransport_RefreshBeforeExpiration(t *testing.T) {
+	server := bearerServer(t)
+	source := &countingSource{duration: 10 * time.Second}
+	transport := authentication.NewTransport(source, nil)
+	transport.RefreshBefore = 20 * time.Second
+	client := &http.Client{Transport: transport}
+
+	_, body := get(t, client, server.URL)
+	assert.Equal(t, "token-1 ", body)
+
+	_, body = get(t, client, server.URL)
+	assert.Equal(t, "token-2 ", body)
+}
+
+func TestTransport_ConcurrentRefresh(t *testing.T) {
+	server := bearerServer(t)
+	source := &countingSource{delay: 50 * time.Millisecond, duration: time.Hour}
+	client := &http.Client{Transport: authentication.NewTransport(source, nil)}
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(server.URL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(1), source.calls.Load())
+}
+
+func TestTransport_RetryUnauthorized(t *testing.T) {
+	server := bearerServer(t, "token-1", "token-2")
+	source := &countingSource{duration: time.Hour}
+	client := &http.Client{Transport: authentication.NewTransport(source, nil)}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The request is only retried once.
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, int32(2), source.calls.Load())
+
+	resp, err = client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "token-3 payload", string(body))
+}
+
+func TestTransport_TokenSources(t *testing.T) {
+	mux := newTestMux()
+
+	protected := authentication.NewMiddleware(authentication.Default("secret", 3600))
+	mux.Handle("GET /service", protected.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := protected.GetClaims(r.Context())
+		require.NoError(t, err)
+
+		_, _ = w.Write([]byte(claims.Subject))
+	})))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name            string
+		source          authentication.TokenSource
+		expectedSubject string
+	}{
+		{
+			name:            "Local login",
+			source:          authentication.LoginTokenSource(protected, "service-a", "issuer", "audience", "service"),
+			expectedSubject: "service-a",
+		},
+		{
+			name:            "Token endpoint",
+			source:          authentication.EndpointTokenSource(server.Client(), server.URL+"/token", "john", "secret"),
+			expectedSubject: "user-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.source.Token(context.Background())
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, 5*time.Second)
+
+			client := &http.Client{Transport: authentication.NewTransport(tt.source, nil)}
+
+			status, body := get(t, client, server.URL+"/service")
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, tt.expectedSubject, body)
+		})
+	}
+}
@@ -1,17 +1,94 @@
 package cors
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const wildcard = "*"
+
+// ErrWildcardCredentials is returned when credentials are allowed with a wildcard, which browsers reject.
+var ErrWildcardCredentials = errors.New("cors: credentials can't be allowed with a wildcard")
+
+var (
+	defaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}
+	defaultHeaders = []string{"Content-Type", "Authorization"}
+)
+
+// Config holds the CORS policy.
+//
+// AllowedOrigins is the list of allowed origins. Every origin is allowed when it's empty or it holds "*".
+// AllowedMethods is the list of allowed methods, which defaults to GET, POST, PUT, DELETE and OPTIONS.
+// AllowedHeaders is the list of allowed request headers, which defaults to Content-Type and Authorization.
+// ExposedHeaders is the list of response headers that browsers expose to scripts.
+// AllowCredentials allows requests with cookies and HTTP authentication, which requires explicit origins.
+// MaxAge is how long preflight responses can be cached. Zero doesn't send it.
+type Config struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
 
 // CORS holds CORS middleware dependencies and related methods.
 type CORS struct {
-	allowedOrigins []string
+	allowedOrigins   []string
+	allowAnyOrigin   bool
+	allowedMethods   string
+	allowedHeaders   string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
 }
 
-// New is a CORS middleware constructor.
+// New is a CORS middleware constructor, with the default methods and headers.
 func New(allowedOrigins []string) CORS {
-	return CORS{
-		allowedOrigins: allowedOrigins,
+	c, _ := NewWithConfig(Config{AllowedOrigins: allowedOrigins})
+
+	return c
+}
+
+// NewWithConfig is a CORS middleware constructor, with the given policy.
+//
+// It fails with ErrWildcardCredentials when credentials are allowed for any origin, method or header.
+func NewWithConfig(config Config) (CORS, error) {
+	allowAnyOrigin := len(config.AllowedOrigins) == 0 || slices.Contains(config.AllowedOrigins, wildcard)
+
+	if config.AllowCredentials && (allowAnyOrigin || slices.Contains(config.AllowedMethods, wildcard) ||
+		slices.Contains(config.AllowedHeaders, wildcard) || slices.Contains(config.ExposedHeaders, wildcard)) {
+		return CORS{}, ErrWildcardCredentials
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultMethods
+	}
+
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultHeaders
+	}
+
+	c := CORS{
+		allowedOrigins:   slices.Clone(config.AllowedOrigins),
+		allowAnyOrigin:   allowAnyOrigin,
+		allowedMethods:   strings.ToUpper(strings.Join(methods, ", ")),
+		allowedHeaders:   strings.Join(headers, ", "),
+		exposedHeaders:   strings.Join(config.ExposedHeaders, ", "),
+		allowCredentials: config.AllowCredentials,
 	}
+
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	return c, nil
 }
 
 // Middleware is responsible to handle CORS allowance
@@ -20,32 +97,38 @@ func (c CORS) Middleware(next http.Handler) http.Handler {
 		origin := r.Header.Get("Origin")
 
 		// If no allowed origins are set, allow all (*)
-		if len(c.allowedOrigins) == 0 {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
+		if c.allowAnyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", wildcard)
 		} else {
-			allowed := false
-
-			for _, allowedOrigin := range c.allowedOrigins {
-				if allowedOrigin == origin {
-					allowed = true
+			// The allowed origin depends on the request, so caches must keep a response per origin.
+			w.Header().Add("Vary", "Origin")
 
-					w.Header().Set("Access-Control-Allow-Origin", origin)
-
-					break
-				}
-			}
-
-			if !allowed {
+			if !slices.Contains(c.allowedOrigins, origin) {
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", c.allowedMethods)
+		w.Header().Set("Access-Control-Allow-Headers", c.allowedHeaders)
+
+		if c.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if c.exposedHeaders != "" {
+			w.Header().Set("Access-Control-Expose-Headers", c.exposedHeaders)
+		}
 
 		if r.Method == http.MethodOptions {
+			if c.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			}
+
 			w.WriteHeader(http.StatusOK)
+
 			return
 		}
 
@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCORS(t *testing.T) {
@@ -68,3 +70,102 @@ func TestCORS(t *testing.T) {
 		assert.Equal(t, "*", resp.Header().Get("Access-Control-Allow-Origin"))
 	})
 }
+
+func TestNewWithConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		expectedErr error
+	}{
+		{
+			name:   "Explicit origins with credentials",
+			config: Config{AllowedOrigins: []string{"http://example.com"}, AllowCredentials: true},
+		},
+		{
+			name:   "Wildcard without credentials",
+			config: Config{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}},
+		},
+		{
+			name:        "Wildcard origin with credentials",
+			config:      Config{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			expectedErr: ErrWildcardCredentials,
+		},
+		{
+			name:        "Any origin with credentials",
+			config:      Config{AllowCredentials: true},
+			expectedErr: ErrWildcardCredentials,
+		},
+		{
+			name: "Wildcard header with credentials",
+			config: Config{
+				AllowedOrigins:   []string{"http://example.com"},
+				AllowedHeaders:   []string{"*"},
+				AllowCredentials: true,
+			},
+			expectedErr: ErrWildcardCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWithConfig(tt.config)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestCORS_Config(t *testing.T) {
+	c, err := NewWithConfig(Config{
+		AllowedOrigins:   []string{"http://example.com"},
+		AllowedMethods:   []string{"get", "patch"},
+		AllowedHeaders:   []string{"Content-Type", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Renewed-Token"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Run("Preflight Request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "http://localhost", nil)
+		req.Header.Set("Origin", "http://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "http://example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, PATCH", resp.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, X-Request-ID", resp.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "600", resp.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "Origin", resp.Header().Get("Vary"))
+	})
+
+	t.Run("Actual Request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "http://localhost", nil)
+		req.Header.Set("Origin", "http://example.com")
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "X-Renewed-Token", resp.Header().Get("Access-Control-Expose-Headers"))
+		assert.Empty(t, resp.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("Default Config", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		req.Header.Set("Origin", "http://example.com")
+		resp := httptest.NewRecorder()
+
+		New(nil).Middleware(http.NotFoundHandler()).ServeHTTP(resp, req)
+		assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", resp.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, Authorization", resp.Header().Get("Access-Control-Allow-Headers"))
+		assert.Empty(t, resp.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Empty(t, resp.Header().Get("Access-Control-Expose-Headers"))
+	})
+}
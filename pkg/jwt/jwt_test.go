@@ -18,7 +18,11 @@ func mockHandler() http.Handler {
 }
 
 func generateToken(secret, role string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return generateMethodToken(jwt.SigningMethodHS256, secret, role)
+}
+
+func generateMethodToken(method jwt.SigningMethod, secret, role string) (string, error) {
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
 		"role": role,
 	})
 	return token.SignedString([]byte(secret))
@@ -27,6 +31,11 @@ func generateToken(secret, role string) (string, error) {
 func TestJWT_Middleware(t *testing.T) {
 	jwtSecret := "secret"
 
+	hs512Token, err := generateMethodToken(jwt.SigningMethodHS512, jwtSecret, "admin")
+	if err != nil {
+		t.Fatalf("could not generate token: %v", err)
+	}
+
 	tests := []struct {
 		name           string
 		token          string
@@ -43,6 +52,13 @@ func TestJWT_Middleware(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"message":"success"}`,
 		},
+		{
+			name:           "HS512 token",
+			token:          hs512Token,
+			requestPath:    "/admin",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message":"success"}`,
+		},
 		{
 			name:           "Invalid token",
 			role:           "admin",
@@ -124,3 +140,19 @@ func TestJWT_Middleware(t *testing.T) {
 		})
 	}
 }
+
+func TestJWT_MiddlewareBuiltOnce(t *testing.T) {
+	jwtMiddleware := New("admin", "secret", 3600, "claims", nil, nil)
+	middleware := jwtMiddleware.middleware
+
+	jwtMiddleware.Middleware(mockHandler())
+	jwtMiddleware.Middleware(mockHandler())
+
+	assert.Same(t, middleware, jwtMiddleware.middleware)
+
+	// A JWT built without New gets its authentication middleware on first use.
+	literal := JWT{AdminRole: "admin", TokenSecret: "secret"}
+	literal.Middleware(mockHandler())
+
+	assert.NotNil(t, literal.middleware)
+}